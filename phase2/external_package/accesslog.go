@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// newLogger builds the process logger in the given format, text or json.
+// Records logged with a request's context carry its request and trace ids.
+func newLogger(format string, w io.Writer) (*slog.Logger, error) {
+	switch format {
+	case "text":
+		return slog.New(contextHandler{slog.NewTextHandler(w, nil)}), nil
+	case "json":
+		return slog.New(contextHandler{slog.NewJSONHandler(w, nil)}), nil
+	}
+	return nil, fmt.Errorf("unknown log format %q; use text or json", format)
+}
+
+// contextHandler adds the ids the tracing middleware put in the context.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := requestIDFrom(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	if s := spanFrom(ctx); s != nil {
+		r.AddAttrs(slog.String("trace_id", traceIDFrom(ctx)), slog.String("span_id", hex.EncodeToString(s.sc.SpanID[:])))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// accessLog logs one line per request. Successful requests (status below
+// 400) are sampled at rate, from 0 to 1; errors are always logged.
+type accessLog struct {
+	logger *slog.Logger
+	rate   float64
+	random func() float64 // in [0, 1); replaced in tests
+}
+
+func newAccessLog(logger *slog.Logger, rate float64) *accessLog {
+	return &accessLog{logger: logger, rate: rate, random: rand.Float64}
+}
+
+// responseRecorder captures what a handler wrote.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the real writer.
+func (rec *responseRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// routeKey holds a *string that recordRoute fills in with the matched
+// route's template. The access log wraps the whole router, so it sees
+// unmatched requests too, but only the router knows which route matched.
+type routeKey struct{}
+
+// withRoute returns the request's route slot, adding one if no outer
+// middleware already has.
+func withRoute(r *http.Request) (*http.Request, *string) {
+	if route, ok := r.Context().Value(routeKey{}).(*string); ok {
+		return r, route
+	}
+	route := new(string)
+	return r.WithContext(context.WithValue(r.Context(), routeKey{}, route)), route
+}
+
+// recordRoute is router middleware that reports the route template, such
+// as /api/v1/users/{id:[0-9]+}, back to the access log.
+func recordRoute(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if route, ok := r.Context().Value(routeKey{}).(*string); ok {
+			if tmpl, err := mux.CurrentRoute(r).GetPathTemplate(); err == nil {
+				*route = tmpl
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (l *accessLog) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if quietPaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		start := time.Now()
+		r, route := withRoute(r)
+		rec := &responseRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+
+		level := slog.LevelInfo
+		switch {
+		case rec.status >= 500:
+			level = slog.LevelError
+		case rec.status >= 400:
+			level = slog.LevelWarn
+		case l.rate < 1 && l.random() >= l.rate:
+			return
+		}
+		l.logger.LogAttrs(r.Context(), level, "request",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.String("route", *route),
+			slog.Int("status", rec.status),
+			slog.Int64("bytes", rec.bytes),
+			slog.Duration("duration", time.Since(start)),
+			slog.String("remote_addr", r.RemoteAddr),
+			slog.String("user_agent", r.UserAgent()),
+		)
+	})
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// logLines decodes a JSON log, one object per line.
+func logLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var lines []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var m map[string]any
+		if err := json.Unmarshal([]byte(line), &m); err != nil {
+			t.Fatalf("decoding log line %q: %v", line, err)
+		}
+		lines = append(lines, m)
+	}
+	buf.Reset()
+	return lines
+}
+
+func TestAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := newLogger("json", &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logs := newAccessLog(logger, 1)
+	srv := httptest.NewServer(newHandler(&api{users: newMemoryUsers()}, logs, newTracer(nil)))
+	defer srv.Close()
+
+	req, _ := http.NewRequest("POST", srv.URL+usersPath, strings.NewReader(`{"name":"Alice","email":"alice@example.com"}`))
+	req.Header.Set("User-Agent", "access-log-test")
+	req.Header.Set("X-Request-ID", "req-1")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	do(t, "GET", srv.URL+usersPath+"/1", "")
+	do(t, "GET", srv.URL+"/nowhere", "")
+	do(t, "GET", srv.URL+"/healthz", "")
+
+	lines := logLines(t, &buf)
+	if len(lines) != 3 {
+		t.Fatalf("logged %d lines; want 3 with /healthz left out:\n%v", len(lines), lines)
+	}
+	tests := []struct {
+		field string
+		line  int
+		want  any
+	}{
+		{"msg", 0, "request"},
+		{"method", 0, "POST"},
+		{"route", 0, "/api/v1/users"},
+		{"status", 0, float64(http.StatusCreated)},
+		{"user_agent", 0, "access-log-test"},
+		{"request_id", 0, "req-1"},
+		{"route", 1, "/api/v1/users/{id:[0-9]+}"},
+		{"path", 1, "/api/v1/users/1"},
+		{"level", 1, "INFO"},
+		{"status", 2, float64(http.StatusNotFound)},
+		{"route", 2, ""},
+		{"level", 2, "WARN"},
+	}
+	for _, tt := range tests {
+		if got := lines[tt.line][tt.field]; got != tt.want {
+			t.Errorf("line %d %s = %v; want %v", tt.line, tt.field, got, tt.want)
+		}
+	}
+	if b, _ := lines[1]["bytes"].(float64); b == 0 {
+		t.Errorf("line 1 bytes = %v; want the body size", lines[1]["bytes"])
+	}
+	if addr, _ := lines[0]["remote_addr"].(string); !strings.HasPrefix(addr, "127.0.0.1:") {
+		t.Errorf("remote_addr = %q; want the client address", addr)
+	}
+
+	// Sampled out: successes are dropped, errors still logged.
+	logs.rate, logs.random = 0.5, func() float64 { return 0.9 }
+	do(t, "GET", srv.URL+usersPath, "")
+	do(t, "GET", srv.URL+usersPath+"/9", "")
+	lines = logLines(t, &buf)
+	if len(lines) != 1 || lines[0]["status"] != float64(http.StatusNotFound) {
+		t.Errorf("sampled log = %v; want only the 404", lines)
+	}
+}
+
+func TestNewLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := newLogger("text", &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("hello", "status", 200)
+	if got := buf.String(); !strings.Contains(got, "msg=hello status=200") {
+		t.Errorf("text log = %q; want key=value pairs", got)
+	}
+	if _, err := newLogger("xml", &buf); err == nil {
+		t.Error("newLogger(xml) should fail")
+	}
+}
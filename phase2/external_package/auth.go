@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	roleAdmin = "admin"
+	roleUser  = "user"
+
+	minSecretLength = 32 // bytes of HMAC key, as RFC 7518 asks for HS256
+	minAPIKeyLength = 16
+	clockSkew       = 30 * time.Second
+	authRealm       = `Bearer realm="users-api"`
+)
+
+// principal is who a request acts as. UserID is the user a "user" role
+// may read and update; admins may not have one.
+type principal struct {
+	Subject string
+	Role    string
+	UserID  int
+}
+
+// apiKey is a static credential, stored by the SHA-256 of the key so the
+// plain keys aren't kept in memory.
+type apiKey struct {
+	name   string
+	role   string
+	userID int
+}
+
+// authenticator checks HS256 JWTs signed with secret and static API keys.
+// Either may be left unset.
+type authenticator struct {
+	secret   []byte
+	issuer   string // required iss claim, if set
+	audience string // required in the aud claim, if set
+	keys     map[[sha256.Size]byte]apiKey
+	now      func() time.Time
+}
+
+func newAuthenticator(secret, issuer, audience string, keys map[[sha256.Size]byte]apiKey) (*authenticator, error) {
+	if secret != "" && len(secret) < minSecretLength {
+		return nil, fmt.Errorf("the JWT secret must be at least %d bytes", minSecretLength)
+	}
+	if secret == "" && len(keys) == 0 {
+		return nil, errors.New("no credentials configured; set a JWT secret or API keys")
+	}
+	return &authenticator{secret: []byte(secret), issuer: issuer, audience: audience, keys: keys, now: time.Now}, nil
+}
+
+// parseAPIKeys reads one key per line as "name role user-id key", where
+// user-id is - for keys not tied to a user. Blank lines and lines
+// starting with # are skipped.
+func parseAPIKeys(r io.Reader) (map[[sha256.Size]byte]apiKey, error) {
+	keys := map[[sha256.Size]byte]apiKey{}
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("line %d: want name, role, user id and key", n)
+		}
+		k := apiKey{name: fields[0], role: fields[1]}
+		if fields[2] != "-" {
+			id, err := strconv.Atoi(fields[2])
+			if err != nil || id < 1 {
+				return nil, fmt.Errorf("line %d: user id %q is not a positive number or -", n, fields[2])
+			}
+			k.userID = id
+		}
+		if err := checkRole(k.role, k.userID); err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		if len(fields[3]) < minAPIKeyLength {
+			return nil, fmt.Errorf("line %d: key must be at least %d characters", n, minAPIKeyLength)
+		}
+		sum := sha256.Sum256([]byte(fields[3]))
+		if _, dup := keys[sum]; dup {
+			return nil, fmt.Errorf("line %d: key is already listed", n)
+		}
+		keys[sum] = k
+	}
+	return keys, sc.Err()
+}
+
+func checkRole(role string, userID int) error {
+	switch {
+	case role != roleAdmin && role != roleUser:
+		return fmt.Errorf("unknown role %q", role)
+	case role == roleUser && userID == 0:
+		return errors.New("a user credential needs a user id")
+	}
+	return nil
+}
+
+// errNoCredentials means the request didn't try to authenticate, which
+// gets a plain challenge rather than error="invalid_token".
+var errNoCredentials = errors.New("authentication required")
+
+// authenticate finds the request's credentials: a bearer JWT in
+// Authorization, or a key in X-API-Key.
+func (a *authenticator) authenticate(r *http.Request) (principal, error) {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		k, ok := a.keys[sha256.Sum256([]byte(key))]
+		if !ok {
+			return principal{}, errors.New("unknown API key")
+		}
+		return principal{Subject: "apikey:" + k.name, Role: k.role, UserID: k.userID}, nil
+	}
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return principal{}, errNoCredentials
+	}
+	return a.verifyJWT(strings.TrimSpace(token))
+}
+
+// jwtClaims are the claims we read. Aud may be a string or a list.
+type jwtClaims struct {
+	Subject   string          `json:"sub"`
+	Role      string          `json:"role"`
+	Issuer    string          `json:"iss"`
+	Audience  json.RawMessage `json:"aud"`
+	ExpiresAt *int64          `json:"exp"`
+	NotBefore *int64          `json:"nbf"`
+}
+
+// verifyJWT checks an HS256 token's signature and claims. Only HS256 is
+// accepted, whatever the header asks for, so "none" and algorithm
+// confusion tricks fail. Tokens must expire; the subject of a user token
+// is its user id.
+func (a *authenticator) verifyJWT(token string) (principal, error) {
+	if len(a.secret) == 0 {
+		return principal{}, errors.New("tokens are not accepted here")
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return principal{}, errors.New("malformed token")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil || header.Alg != "HS256" {
+		return principal{}, errors.New("token must be signed with HS256")
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	mac := hmac.New(sha256.New, a.secret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if err != nil || !hmac.Equal(sig, mac.Sum(nil)) {
+		return principal{}, errors.New("bad token signature")
+	}
+
+	var c jwtClaims
+	if err := decodeSegment(parts[1], &c); err != nil {
+		return principal{}, errors.New("malformed token claims")
+	}
+	now := a.now()
+	switch {
+	case c.ExpiresAt == nil:
+		return principal{}, errors.New("token has no expiry")
+	case now.After(time.Unix(*c.ExpiresAt, 0).Add(clockSkew)):
+		return principal{}, errors.New("token has expired")
+	case c.NotBefore != nil && now.Add(clockSkew).Before(time.Unix(*c.NotBefore, 0)):
+		return principal{}, errors.New("token is not valid yet")
+	case a.issuer != "" && c.Issuer != a.issuer:
+		return principal{}, errors.New("token is from another issuer")
+	case a.audience != "" && !hasAudience(c.Audience, a.audience):
+		return principal{}, errors.New("token is for another audience")
+	}
+
+	p := principal{Subject: c.Subject, Role: c.Role}
+	if id, err := strconv.Atoi(c.Subject); err == nil {
+		p.UserID = id
+	}
+	if err := checkRole(p.Role, p.UserID); err != nil {
+		return principal{}, fmt.Errorf("token: %w", err)
+	}
+	return p, nil
+}
+
+func decodeSegment(s string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func hasAudience(raw json.RawMessage, want string) bool {
+	var one string
+	if json.Unmarshal(raw, &one) == nil {
+		return one == want
+	}
+	var many []string
+	json.Unmarshal(raw, &many)
+	for _, aud := range many {
+		if aud == want {
+			return true
+		}
+	}
+	return false
+}
+
+type principalKey struct{}
+
+func principalFrom(ctx context.Context) (principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(principal)
+	return p, ok
+}
+
+// middleware rejects requests without valid credentials with a 401 and
+// puts the principal in the context for the authorization checks.
+func (a *authenticator) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, err := a.authenticate(r)
+		if err != nil {
+			challenge := authRealm
+			if !errors.Is(err, errNoCredentials) {
+				challenge += `, error="invalid_token"`
+			}
+			w.Header().Set("WWW-Authenticate", challenge)
+			writeProblem(w, r, http.StatusUnauthorized, err.Error())
+			return
+		}
+		if s := spanFrom(r.Context()); s != nil {
+			s.setAttr("enduser.id", p.Subject)
+			s.setAttr("enduser.role", p.Role)
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), principalKey{}, p)))
+	})
+}
+
+// adminOnly lets only admins through to h; what says what was refused.
+// Without an authenticator every route is open, so it returns h as is.
+func (a *api) adminOnly(what string, h http.HandlerFunc) http.HandlerFunc {
+	if a.auth == nil {
+		return h
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if p, _ := principalFrom(r.Context()); p.Role != roleAdmin {
+			writeProblem(w, r, http.StatusForbidden, "only admins can "+what)
+			return
+		}
+		h(w, r)
+	}
+}
+
+// selfOrAdmin lets a user through to h for their own record only.
+func (a *api) selfOrAdmin(h http.HandlerFunc) http.HandlerFunc {
+	if a.auth == nil {
+		return h
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if p, _ := principalFrom(r.Context()); p.Role != roleAdmin && p.UserID != userID(r) {
+			writeProblem(w, r, http.StatusForbidden, "users can only see and change their own record")
+			return
+		}
+		h(w, r)
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+// testAuthenticator accepts tokens signed with testSecret and the API keys
+// listed, one per line, in parseAPIKeys form.
+func testAuthenticator(t *testing.T, keys ...string) *authenticator {
+	t.Helper()
+	parsed, err := parseAPIKeys(strings.NewReader(strings.Join(keys, "\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth, err := newAuthenticator(testSecret, "", "", parsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return auth
+}
+
+// signJWT makes a token with the given header algorithm and claims.
+func signJWT(alg, secret string, claims map[string]any) string {
+	enc := func(v any) string {
+		data, _ := json.Marshal(v)
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	unsigned := enc(map[string]string{"alg": alg, "typ": "JWT"}) + "." + enc(claims)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifyJWT(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	auth, err := newAuthenticator(testSecret, "issuer", "users-api", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth.now = func() time.Time { return now }
+
+	claims := func(changes map[string]any) map[string]any {
+		c := map[string]any{"sub": "7", "role": "user", "iss": "issuer", "aud": "users-api", "exp": now.Add(time.Hour).Unix()}
+		for k, v := range changes {
+			if v == nil {
+				delete(c, k)
+			} else {
+				c[k] = v
+			}
+		}
+		return c
+	}
+	tests := []struct {
+		desc  string
+		token string
+		err   string
+	}{
+		{"valid", signJWT("HS256", testSecret, claims(nil)), ""},
+		{"audience list", signJWT("HS256", testSecret, claims(map[string]any{"aud": []string{"other", "users-api"}})), ""},
+		{"admin without id", signJWT("HS256", testSecret, claims(map[string]any{"role": "admin", "sub": "ops"})), ""},
+		{"within skew", signJWT("HS256", testSecret, claims(map[string]any{"exp": now.Add(-10 * time.Second).Unix()})), ""},
+		{"expired", signJWT("HS256", testSecret, claims(map[string]any{"exp": now.Add(-time.Hour).Unix()})), "expired"},
+		{"no expiry", signJWT("HS256", testSecret, claims(map[string]any{"exp": nil})), "no expiry"},
+		{"not yet", signJWT("HS256", testSecret, claims(map[string]any{"nbf": now.Add(time.Hour).Unix()})), "not valid yet"},
+		{"wrong key", signJWT("HS256", strings.Repeat("x", 32), claims(nil)), "signature"},
+		{"alg none", strings.Join(strings.Split(signJWT("none", testSecret, claims(nil)), ".")[:2], ".") + ".", "HS256"},
+		{"alg HS512", signJWT("HS512", testSecret, claims(nil)), "HS256"},
+		{"issuer", signJWT("HS256", testSecret, claims(map[string]any{"iss": "elsewhere"})), "issuer"},
+		{"audience", signJWT("HS256", testSecret, claims(map[string]any{"aud": "billing"})), "audience"},
+		{"unknown role", signJWT("HS256", testSecret, claims(map[string]any{"role": "root"})), "unknown role"},
+		{"user without id", signJWT("HS256", testSecret, claims(map[string]any{"sub": "bob"})), "user id"},
+		{"malformed", "not.a-token", "malformed"},
+	}
+	for _, tt := range tests {
+		p, err := auth.verifyJWT(tt.token)
+		switch {
+		case tt.err == "" && err != nil:
+			t.Errorf("%s: verifyJWT = %v", tt.desc, err)
+		case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+			t.Errorf("%s: verifyJWT error = %v; want %q", tt.desc, err, tt.err)
+		case tt.desc == "valid" && (p != principal{Subject: "7", Role: roleUser, UserID: 7}):
+			t.Errorf("valid token principal = %+v", p)
+		}
+	}
+
+	if _, err := newAuthenticator("short", "", "", nil); err == nil {
+		t.Error("newAuthenticator with a short secret should fail")
+	}
+	if _, err := newAuthenticator("", "", "", nil); err == nil {
+		t.Error("newAuthenticator with no credentials should fail")
+	}
+}
+
+func TestParseAPIKeys(t *testing.T) {
+	keys, err := parseAPIKeys(strings.NewReader(`
+# ops
+deploy admin - deploy-key-0123456789
+alice  user  1 alice-key-0123456789
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k := keys[sha256.Sum256([]byte("alice-key-0123456789"))]; k != (apiKey{name: "alice", role: roleUser, userID: 1}) {
+		t.Errorf("alice's key = %+v", k)
+	}
+
+	for _, bad := range []string{
+		"deploy admin - short",
+		"deploy root - deploy-key-0123456789",
+		"alice user - alice-key-0123456789",
+		"alice user x alice-key-0123456789",
+		"deploy admin deploy-key-0123456789",
+		"a admin - same-key-0123456789\nb admin - same-key-0123456789",
+	} {
+		if _, err := parseAPIKeys(strings.NewReader(bad)); err == nil {
+			t.Errorf("parseAPIKeys(%q) should fail", bad)
+		}
+	}
+}
+
+func TestAuthorization(t *testing.T) {
+	srv := newTestServer(t)
+	users := srv.URL + usersPath
+	do(t, "POST", users, `{"name":"Alice","email":"alice@example.com"}`)
+	do(t, "POST", users, `{"name":"Bob","email":"bob@example.com"}`)
+
+	token := func(sub, role string) string {
+		return "Bearer " + signJWT("HS256", testSecret, map[string]any{"sub": sub, "role": role, "exp": time.Now().Add(time.Hour).Unix()})
+	}
+	alice := token("1", roleUser)
+	admin := token("ops", roleAdmin)
+	expired := "Bearer " + signJWT("HS256", testSecret, map[string]any{"sub": "1", "role": "user", "exp": time.Now().Add(-time.Hour).Unix()})
+
+	tests := []struct {
+		method, path, body string
+		authz              string // Authorization header
+		status             int
+	}{
+		{"GET", "/1", "", "", http.StatusUnauthorized},
+		{"GET", "/1", "", expired, http.StatusUnauthorized},
+		{"GET", "/1", "", "Basic YWxpY2U6c2VjcmV0", http.StatusUnauthorized},
+		{"GET", "/1", "", alice, http.StatusOK},
+		{"PATCH", "/1", `{"name":"Alice Smith"}`, alice, http.StatusOK},
+		{"PUT", "/1", `{"name":"Alice","email":"alice@example.com"}`, alice, http.StatusOK},
+		{"GET", "/2", "", alice, http.StatusForbidden},
+		{"PATCH", "/2", `{"name":"Hacked"}`, alice, http.StatusForbidden},
+		{"GET", "", "", alice, http.StatusForbidden},
+		{"POST", "", `{"name":"Eve","email":"eve@example.com"}`, alice, http.StatusForbidden},
+		{"DELETE", "/1", "", alice, http.StatusForbidden},
+		{"GET", "", "", admin, http.StatusOK},
+		{"GET", "/2", "", admin, http.StatusOK},
+		{"POST", "", `{"name":"Eve","email":"eve@example.com"}`, admin, http.StatusCreated},
+		{"DELETE", "/3", "", admin, http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(tt.method, users+tt.path, strings.NewReader(tt.body))
+		if tt.authz != "" {
+			req.Header.Set("Authorization", tt.authz)
+		}
+		resp, body := send(t, req)
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s %s as %.20s = %d; want %d (%s)", tt.method, tt.path, tt.authz, resp.StatusCode, tt.status, body)
+			continue
+		}
+		if tt.status == http.StatusUnauthorized || tt.status == http.StatusForbidden {
+			if p := decodeProblem(t, resp, body); p.Status != tt.status || p.Detail == "" {
+				t.Errorf("%s %s problem = %+v", tt.method, tt.path, p)
+			}
+		}
+		if tt.status == http.StatusUnauthorized {
+			want := authRealm
+			if tt.authz == expired {
+				want += `, error="invalid_token"`
+			}
+			if got := resp.Header.Get("WWW-Authenticate"); got != want {
+				t.Errorf("%s %s WWW-Authenticate = %q; want %q", tt.method, tt.path, got, want)
+			}
+		}
+	}
+
+	// A bad API key is refused even alongside a good token.
+	req, _ := http.NewRequest("GET", users+"/1", nil)
+	req.Header.Set("X-API-Key", "not-a-real-key-at-all")
+	req.Header.Set("Authorization", alice)
+	if resp, _ := send(t, req); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("unknown API key = %d; want 401", resp.StatusCode)
+	}
+	// Probes stay public.
+	req, _ = http.NewRequest("GET", srv.URL+"/healthz", nil)
+	if resp, _ := send(t, req); resp.StatusCode != http.StatusOK {
+		t.Errorf("healthz without credentials = %d; want 200", resp.StatusCode)
+	}
+}
@@ -1,15 +1,20 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/gorilla/mux" // Third-party router
-	// PostgreSQL driver
-	_ "github.com/lib/pq" // Import for side effects only
 )
 
 // Using external package - JSON handling
@@ -19,62 +24,202 @@ type User struct {
 	Email string `json:"email"`
 }
 
-func handleUsers(w http.ResponseWriter, r *http.Request) {
-	users := []User{
-		{ID: 1, Name: "Alice", Email: "alice@example.com"},
-		{ID: 2, Name: "Bob", Email: "bob@example.com"},
+func newRouter(a *api) *mux.Router {
+	// Using gorilla/mux for routing
+	r := mux.NewRouter()
+
+	// API routes. Each path gets a subrouter with one route per method:
+	// when a path's routes sit directly on a shared subrouter, a later
+	// route's path mismatch masks an earlier method mismatch, and the 405
+	// comes out as a 404.
+	//
+	// Admins manage users; a user may read and update only their own
+	// record.
+	api := r.PathPrefix("/api/v1").Subrouter()
+	users := api.Path("/users").Subrouter()
+	users.Methods("GET").HandlerFunc(a.adminOnly("list users", a.handleUsers))
+	users.Methods("POST").HandlerFunc(a.adminOnly("create users", a.handleCreateUser))
+	user := api.Path("/users/{id:[0-9]+}").Subrouter()
+	user.Methods("GET").HandlerFunc(a.selfOrAdmin(a.handleUser))
+	user.Methods("PUT").HandlerFunc(a.selfOrAdmin(a.handleReplaceUser))
+	user.Methods("PATCH").HandlerFunc(a.selfOrAdmin(a.handlePatchUser))
+	user.Methods("DELETE").HandlerFunc(a.adminOnly("delete users", a.handleDeleteUser))
+	if a.auth != nil {
+		api.Use(a.auth.middleware)
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(users)
-}
+	// Probes and build info, kept out of the request log.
+	r.HandleFunc("/healthz", handleHealth).Methods("GET")
+	r.HandleFunc("/readyz", a.handleReady).Methods("GET")
+	r.HandleFunc("/version", handleVersion).Methods("GET")
 
-func handleUser(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	userID := vars["id"]
+	// Static file serving
+	r.PathPrefix("/static/").Handler(http.StripPrefix("/static/",
+		http.FileServer(http.Dir("./static/"))))
 
-	user := User{
-		ID:    1,
-		Name:  "User " + userID,
-		Email: "user" + userID + "@example.com",
+	// Errors in the problem format.
+	r.NotFoundHandler = http.HandlerFunc(notFound)
+	for _, router := range []*mux.Router{users, user} {
+		router.MethodNotAllowedHandler = http.HandlerFunc(methodNotAllowed)
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(user)
+	// Middleware
+	r.Use(recordRoute)
+
+	return r
+}
+
+// newHandler puts the router behind the request-wide middleware. Tracing
+// comes first, so the access log sees the request id and trace.
+func newHandler(a *api, logs *accessLog, tracer *tracer) http.Handler {
+	return tracer.middleware(logs.middleware(newRouter(a)))
 }
 
 func main() {
-	// Using gorilla/mux for routing
-	r := mux.NewRouter()
+	if err := run(); err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+}
 
-	// API routes
-	api := r.PathPrefix("/api/v1").Subrouter()
-	api.HandleFunc("/users", handleUsers).Methods("GET")
-	api.HandleFunc("/users/{id:[0-9]+}", handleUser).Methods("GET")
+// run is main with errors returned, so deferred cleanup such as closing
+// the database pool happens on every exit path.
+func run() error {
+	databaseURL := flag.String("database-url", os.Getenv("DATABASE_URL"), "PostgreSQL connection URL; users are kept in memory when empty")
+	pool := defaultPool
+	flag.IntVar(&pool.MaxOpen, "db-max-open", pool.MaxOpen, "maximum open database connections")
+	flag.IntVar(&pool.MaxIdle, "db-max-idle", pool.MaxIdle, "maximum idle database connections")
+	flag.DurationVar(&pool.MaxLifetime, "db-conn-lifetime", pool.MaxLifetime, "close database connections after this long")
+	flag.DurationVar(&pool.MaxIdleTime, "db-conn-idle-time", pool.MaxIdleTime, "close database connections idle for this long")
+	migrateTo := flag.String("migrate", "", "migrate the database up, down (one version) or to a version number, then exit")
+	checkTimeout := flag.Duration("check-timeout", 2*time.Second, "time each /readyz dependency check may take")
+	server := defaultServer
+	flag.StringVar(&server.Addr, "addr", server.Addr, "address to listen on")
+	flag.DurationVar(&server.ReadHeaderTimeout, "read-header-timeout", server.ReadHeaderTimeout, "time allowed to read request headers")
+	flag.DurationVar(&server.ReadTimeout, "read-timeout", server.ReadTimeout, "time allowed to read a whole request")
+	flag.DurationVar(&server.WriteTimeout, "write-timeout", server.WriteTimeout, "time allowed to write a response")
+	flag.DurationVar(&server.IdleTimeout, "idle-timeout", server.IdleTimeout, "close keep-alive connections idle for this long")
+	flag.DurationVar(&server.DrainDelay, "drain-delay", server.DrainDelay, "on shutdown, keep serving this long after reporting not ready")
+	flag.DurationVar(&server.ShutdownTimeout, "shutdown-timeout", server.ShutdownTimeout, "on shutdown, wait this long for in-flight requests")
+	logFormat := flag.String("log-format", "text", "log format, text or json")
+	logSample := flag.Float64("log-sample", 1, "fraction of successful requests to log, from 0 to 1; errors are always logged")
+	traceExporter := flag.String("trace-exporter", "none", "where to send spans: none, stdout or otlp")
+	jwtSecret := flag.String("jwt-secret", os.Getenv("JWT_SECRET"), "HMAC key for verifying HS256 bearer tokens, at least 32 bytes")
+	jwtIssuer := flag.String("jwt-issuer", "", "require this iss claim in tokens")
+	jwtAudience := flag.String("jwt-audience", "", "require this aud claim in tokens")
+	apiKeysFile := flag.String("api-keys", "", `file of static API keys, one "name role user-id key" per line`)
+	noAuth := flag.Bool("insecure-no-auth", false, "serve the API without authentication, for local development")
+	otlpEndpoint := flag.String("otlp-endpoint", os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"), "OTLP/HTTP collector base URL for -trace-exporter otlp, e.g. http://localhost:4318")
+	flag.Parse()
 
-	// Static file serving
-	r.PathPrefix("/static/").Handler(http.StripPrefix("/static/",
-		http.FileServer(http.Dir("./static/"))))
+	logger, err := newLogger(*logFormat, os.Stdout)
+	if err != nil {
+		return err
+	}
+	if *logSample < 0 || *logSample > 1 {
+		return fmt.Errorf("-log-sample %v is not between 0 and 1", *logSample)
+	}
+	// The log package writes through the same handler.
+	slog.SetDefault(logger)
 
-	// Middleware
-	r.Use(loggingMiddleware)
-
-	// Server configuration
-	srv := &http.Server{
-		Handler:      r,
-		Addr:         ":8080",
-		WriteTimeout: 15 * time.Second,
-		ReadTimeout:  15 * time.Second,
+	exporter, err := newExporter(*traceExporter, *otlpEndpoint, os.Stdout)
+	if err != nil {
+		return err
 	}
+	tracer := newTracer(exporter)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := tracer.shutdown(ctx); err != nil {
+			slog.Warn("tracer shutdown", "err", err)
+		}
+	}()
 
-	fmt.Println("Server starting on :8080")
-	log.Fatal(srv.ListenAndServe())
-}
+	if *migrateTo != "" && *databaseURL == "" {
+		return errors.New("-migrate needs -database-url")
+	}
+
+	// The first SIGINT or SIGTERM starts a graceful shutdown; a second one
+	// kills the process.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	var (
+		users  UserRepository
+		checks []healthCheck
+	)
+	if *databaseURL != "" {
+		// The ping gets its own deadline so an unreachable database fails
+		// startup instead of hanging it until a signal arrives.
+		openCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		db, err := openDB(openCtx, *databaseURL, pool)
+		cancel()
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		m, err := newMigrator(db)
+		if err != nil {
+			return err
+		}
+		if *migrateTo != "" {
+			return m.migrate(ctx, *migrateTo)
+		}
+		// Bring the schema up to date before serving. Another instance may
+		// hold the migration lock, so this waits for it rather than timing
+		// out.
+		if err := m.migrate(ctx, "up"); err != nil {
+			return err
+		}
+		users = newPostgresUsers(db)
+		checks = append(checks, healthCheck{name: "database", timeout: *checkTimeout, check: db.PingContext})
+	} else {
+		mem := newMemoryUsers()
+		for _, u := range []User{
+			{Name: "Alice", Email: "alice@example.com"},
+			{Name: "Bob", Email: "bob@example.com"},
+		} {
+			if _, err := mem.Create(ctx, u); err != nil {
+				return err
+			}
+		}
+		users = mem
+	}
 
-func loggingMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
-		next.ServeHTTP(w, r)
-		fmt.Printf("%s %s %v\n", r.Method, r.URL.Path, time.Since(start))
-	})
+	// Only serving needs credentials; a -migrate run has returned by now.
+	var auth *authenticator
+	if !*noAuth {
+		keys := map[[sha256.Size]byte]apiKey{}
+		if *apiKeysFile != "" {
+			f, err := os.Open(*apiKeysFile)
+			if err != nil {
+				return err
+			}
+			keys, err = parseAPIKeys(f)
+			f.Close()
+			if err != nil {
+				return fmt.Errorf("%s: %w", *apiKeysFile, err)
+			}
+		}
+		if auth, err = newAuthenticator(*jwtSecret, *jwtIssuer, *jwtAudience, keys); err != nil {
+			return fmt.Errorf("%w, or pass -insecure-no-auth", err)
+		}
+	}
+
+	a := &api{users: tracedUsers{next: users, tracer: tracer}, auth: auth, checks: checks}
+	ln, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		return err
+	}
+	slog.Info("server starting", "addr", ln.Addr().String())
+	handler := newHandler(a, newAccessLog(logger, *logSample), tracer)
+	if err := serve(ctx, newServer(server, handler), ln, server, func() { a.shuttingDown.Store(true) }); err != nil {
+		return err
+	}
+	slog.Info("server stopped")
+	return nil
 }
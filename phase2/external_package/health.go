@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// healthCheck is one dependency /readyz asks about. check must give up
+// when ctx is done; timeout bounds each call.
+type healthCheck struct {
+	name    string
+	timeout time.Duration
+	check   func(ctx context.Context) error
+}
+
+// checkResult is one check's line in the /readyz report.
+type checkResult struct {
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+type readiness struct {
+	Status string                 `json:"status"`
+	Checks map[string]checkResult `json:"checks,omitempty"`
+}
+
+// quietPaths are polled by orchestrators and left out of the request log.
+var quietPaths = map[string]bool{"/healthz": true, "/readyz": true, "/version": true}
+
+// handleHealth is the liveness probe: answering at all is the answer.
+func handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// handleReady reports whether the server should get traffic: every check
+// passes and shutdown hasn't started. Checks run concurrently, so one slow
+// dependency costs its own timeout rather than the sum.
+func (a *api) handleReady(w http.ResponseWriter, r *http.Request) {
+	if a.shuttingDown.Load() {
+		writeJSON(w, http.StatusServiceUnavailable, readiness{Status: "shutting down"})
+		return
+	}
+
+	report := readiness{Status: "ok", Checks: map[string]checkResult{}}
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for _, c := range a.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res := runCheck(r.Context(), c)
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[c.name] = res
+			if res.Status != "ok" {
+				report.Status = "unavailable"
+			}
+		}()
+	}
+	wg.Wait()
+
+	status := http.StatusOK
+	if report.Status != "ok" {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, report)
+}
+
+func runCheck(ctx context.Context, c healthCheck) checkResult {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	start := time.Now()
+	err := c.check(ctx)
+	res := checkResult{Status: "ok", Duration: time.Since(start).Round(time.Microsecond).String()}
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		res.Status, res.Error = "timeout", fmt.Sprintf("no answer within %v", c.timeout)
+	case err != nil:
+		res.Status, res.Error = "error", err.Error()
+	}
+	return res
+}
+
+// version is set at build time with -ldflags "-X main.version=v1.2.3";
+// otherwise the module version is used.
+var version string
+
+type buildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	Modified  bool   `json:"modified,omitempty"`
+	BuildTime string `json:"build_time,omitempty"`
+	GoVersion string `json:"go_version"`
+}
+
+// readBuildInfo gathers what the binary knows about its own build. The
+// vcs settings are only there when built from a checkout with go build.
+func readBuildInfo() buildInfo {
+	info := buildInfo{Version: version, GoVersion: runtime.Version()}
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	if info.Version == "" {
+		info.Version = bi.Main.Version
+	}
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			info.Commit = s.Value
+		case "vcs.modified":
+			info.Modified = s.Value == "true"
+		case "vcs.time":
+			info.BuildTime = s.Value
+		}
+	}
+	return info
+}
+
+func handleVersion(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, readBuildInfo())
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestReadiness(t *testing.T) {
+	ok := healthCheck{name: "cache", timeout: time.Second, check: func(context.Context) error { return nil }}
+	failing := healthCheck{name: "database", timeout: time.Second, check: func(context.Context) error { return errors.New("connection refused") }}
+	slow := healthCheck{name: "queue", timeout: 20 * time.Millisecond, check: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}}
+
+	tests := []struct {
+		desc     string
+		checks   []healthCheck
+		shutdown bool
+		status   int
+		want     map[string]string // check name to status
+	}{
+		{"no checks", nil, false, http.StatusOK, map[string]string{}},
+		{"all pass", []healthCheck{ok}, false, http.StatusOK, map[string]string{"cache": "ok"}},
+		{"one fails", []healthCheck{ok, failing}, false, http.StatusServiceUnavailable, map[string]string{"cache": "ok", "database": "error"}},
+		{"one hangs", []healthCheck{slow, ok}, false, http.StatusServiceUnavailable, map[string]string{"queue": "timeout", "cache": "ok"}},
+		{"shutting down", []healthCheck{ok}, true, http.StatusServiceUnavailable, nil},
+	}
+	for _, tt := range tests {
+		a := &api{users: newMemoryUsers(), checks: tt.checks}
+		a.shuttingDown.Store(tt.shutdown)
+		srv := httptest.NewServer(newRouter(a))
+		resp, body := do(t, "GET", srv.URL+"/readyz", "")
+		srv.Close()
+
+		var report readiness
+		if err := json.Unmarshal([]byte(body), &report); err != nil {
+			t.Fatalf("%s: decoding %q: %v", tt.desc, body, err)
+		}
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s: status = %d; want %d (%s)", tt.desc, resp.StatusCode, tt.status, body)
+		}
+		if len(report.Checks) != len(tt.want) {
+			t.Errorf("%s: checks = %v; want %v", tt.desc, report.Checks, tt.want)
+		}
+		for name, status := range tt.want {
+			if got := report.Checks[name]; got.Status != status || (status != "ok") != (got.Error != "") {
+				t.Errorf("%s: check %s = %+v; want status %s", tt.desc, name, got, status)
+			}
+		}
+	}
+}
+
+func TestHealthAndVersion(t *testing.T) {
+	srv := newTestServer(t)
+	if resp, body := do(t, "GET", srv.URL+"/healthz", ""); resp.StatusCode != http.StatusOK {
+		t.Errorf("healthz = %d %s; want 200", resp.StatusCode, body)
+	}
+
+	resp, body := do(t, "GET", srv.URL+"/version", "")
+	var info buildInfo
+	if err := json.Unmarshal([]byte(body), &info); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("version = %d %s (%v)", resp.StatusCode, body, err)
+	}
+	if info.GoVersion != runtime.Version() {
+		t.Errorf("go_version = %q; want %q", info.GoVersion, runtime.Version())
+	}
+
+	defer func(v string) { version = v }(version)
+	version = "v1.2.3"
+	if got := readBuildInfo().Version; got != "v1.2.3" {
+		t.Errorf("version with -X main.version = %q; want v1.2.3", got)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startServer runs serve on a free port with handler h. It returns the
+// base URL, a cancel that starts shutdown, and serve's result.
+func startServer(t *testing.T, cfg serverConfig, h http.Handler, drain func()) (string, context.CancelFunc, <-chan error) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	done := make(chan error, 1)
+	go func() { done <- serve(ctx, newServer(cfg, h), ln, cfg, drain) }()
+	return "http://" + ln.Addr().String(), cancel, done
+}
+
+func TestGracefulShutdown(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	a := &api{users: newMemoryUsers()}
+	r := newRouter(a)
+	r.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		io.WriteString(w, "done")
+	})
+	cfg := defaultServer
+	cfg.DrainDelay = 100 * time.Millisecond
+	url, shutdown, done := startServer(t, cfg, r, func() { a.shuttingDown.Store(true) })
+
+	if resp, _ := do(t, "GET", url+"/readyz", ""); resp.StatusCode != http.StatusOK {
+		t.Errorf("readyz before shutdown = %d; want 200", resp.StatusCode)
+	}
+
+	slow := make(chan string)
+	go func() {
+		resp, err := http.Get(url + "/slow")
+		if err != nil {
+			slow <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		slow <- string(body)
+	}()
+	<-started
+	shutdown()
+
+	// During the drain delay the server still answers, but not ready.
+	time.Sleep(20 * time.Millisecond)
+	if resp, _ := do(t, "GET", url+"/readyz", ""); resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("readyz while draining = %d; want 503", resp.StatusCode)
+	}
+
+	close(release)
+	if got := <-slow; got != "done" {
+		t.Errorf("in-flight request got %q; want it to finish", got)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("serve = %v; want a clean shutdown", err)
+	}
+	if _, err := http.Get(url + "/readyz"); err == nil {
+		t.Error("server still accepting connections after shutdown")
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{})
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})
+	cfg := defaultServer
+	cfg.ShutdownTimeout = 50 * time.Millisecond
+	url, shutdown, done := startServer(t, cfg, h, func() {})
+
+	go http.Get(url)
+	<-started
+	shutdown()
+	if err := <-done; err == nil || !strings.Contains(err.Error(), "cut off") {
+		t.Errorf("serve with a stuck request = %v; want it cut off", err)
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// memoryUsers keeps users in a map, for running without a database.
+type memoryUsers struct {
+	mu     sync.RWMutex
+	nextID int
+	users  map[int]User
+}
+
+func newMemoryUsers() *memoryUsers {
+	return &memoryUsers{nextID: 1, users: map[int]User{}}
+}
+
+func (m *memoryUsers) List(ctx context.Context, q UserQuery) (UserPage, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	users := make([]User, 0, len(m.users))
+	for _, u := range m.users {
+		if strings.Contains(strings.ToLower(u.Name), strings.ToLower(q.Name)) &&
+			(q.Email == "" || strings.EqualFold(u.Email, q.Email)) {
+			users = append(users, u)
+		}
+	}
+	page := UserPage{Total: len(users)}
+
+	// Read backwards from a backward cursor, then flip the page round.
+	backward := q.Cursor != nil && q.Cursor.Backward
+	slices.SortFunc(users, func(a, b User) int {
+		if backward {
+			a, b = b, a
+		}
+		return compareUsers(a, b, q.Sort)
+	})
+	if q.Cursor != nil {
+		from := keyUser(q.Cursor.Key, q.Sort)
+		users = slices.DeleteFunc(users, func(u User) bool {
+			c := compareUsers(u, from, q.Sort)
+			return c == 0 || (c < 0) != backward
+		})
+	}
+	users = users[min(q.Offset, len(users)):]
+	if len(users) > q.Limit {
+		users, page.More = users[:q.Limit], true
+	}
+	if backward {
+		slices.Reverse(users)
+	}
+	page.Users = users
+	return page, nil
+}
+
+func (m *memoryUsers) Get(ctx context.Context, id int) (User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	u, ok := m.users[id]
+	if !ok {
+		return User{}, ErrUserNotFound
+	}
+	return u, nil
+}
+
+func (m *memoryUsers) Create(ctx context.Context, u User) (User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.emailTaken(u.Email, 0) {
+		return User{}, ErrEmailTaken
+	}
+	u.ID = m.nextID
+	m.nextID++
+	m.users[u.ID] = u
+	return u, nil
+}
+
+func (m *memoryUsers) Update(ctx context.Context, u User) (User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[u.ID]; !ok {
+		return User{}, ErrUserNotFound
+	}
+	if m.emailTaken(u.Email, u.ID) {
+		return User{}, ErrEmailTaken
+	}
+	m.users[u.ID] = u
+	return u, nil
+}
+
+func (m *memoryUsers) Delete(ctx context.Context, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[id]; !ok {
+		return ErrUserNotFound
+	}
+	delete(m.users, id)
+	return nil
+}
+
+// emailTaken reports whether a user other than except has email. Emails
+// are compared case-insensitively, as mail servers treat them.
+func (m *memoryUsers) emailTaken(email string, except int) bool {
+	for id, u := range m.users {
+		if id != except && strings.EqualFold(u.Email, email) {
+			return true
+		}
+	}
+	return false
+}
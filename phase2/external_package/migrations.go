@@ -0,0 +1,303 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// Migrations are pairs of files named VERSION_NAME.up.sql and
+// VERSION_NAME.down.sql, applied in version order. Never edit one that has
+// been applied anywhere; add a new version instead.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockID is the Postgres advisory lock held while migrating, so
+// instances started together don't run the same migration twice. Any
+// number works as long as nothing else in the database uses it.
+const migrationLockID = 0x75736572 // "user"
+
+type migration struct {
+	version int
+	name    string
+	up      string
+	down    string
+}
+
+// checksum identifies the up SQL that was applied, to catch a migration
+// edited after the fact.
+func (m migration) checksum() string {
+	sum := sha256.Sum256([]byte(m.up))
+	return hex.EncodeToString(sum[:])
+}
+
+func (m migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.version, m.name)
+}
+
+var migrationFile = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// loadMigrations reads the migrations in the root of fsys, sorted by
+// version.
+func loadMigrations(fsys fs.FS) ([]migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int]*migration{}
+	for _, e := range entries {
+		match := migrationFile.FindStringSubmatch(e.Name())
+		if match == nil {
+			return nil, fmt.Errorf("migration %s: name must be VERSION_NAME.up.sql or VERSION_NAME.down.sql", e.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		if version == 0 {
+			return nil, fmt.Errorf("migration %s: versions start at 1", e.Name())
+		}
+		data, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		m := byVersion[version]
+		if m == nil {
+			m = &migration{version: version, name: match[2]}
+			byVersion[version] = m
+		}
+		if m.name != match[2] {
+			return nil, fmt.Errorf("migration %s: version %d is also named %s", e.Name(), version, m.name)
+		}
+		if match[3] == "up" {
+			m.up = string(data)
+		} else {
+			m.down = string(data)
+		}
+	}
+
+	migrations := make([]migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.up == "" || m.down == "" {
+			return nil, fmt.Errorf("migration %s: needs both an up and a down file", m)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].version < migrations[j].version })
+	return migrations, nil
+}
+
+// appliedMigration is a row of the schema_migrations table.
+type appliedMigration struct {
+	version  int
+	checksum string
+}
+
+// verifyApplied checks the database's history against the migration files:
+// every applied migration must still exist unchanged, and no migration may
+// have been added below one already applied.
+func verifyApplied(migrations []migration, applied []appliedMigration) error {
+	files := map[int]migration{}
+	for _, m := range migrations {
+		files[m.version] = m
+	}
+	done := map[int]bool{}
+	latest := 0
+	for _, a := range applied {
+		m, ok := files[a.version]
+		if !ok {
+			return fmt.Errorf("migration %d is applied but its files are missing", a.version)
+		}
+		if m.checksum() != a.checksum {
+			return fmt.Errorf("migration %s was edited after it was applied", m)
+		}
+		done[a.version] = true
+		latest = max(latest, a.version)
+	}
+	for _, m := range migrations {
+		if m.version < latest && !done[m.version] {
+			return fmt.Errorf("migration %s is older than applied version %d but was never applied", m, latest)
+		}
+	}
+	return nil
+}
+
+// targetVersion resolves a -migrate argument: "up" is the latest version,
+// "down" undoes the latest applied one, and a number is that version (0
+// undoes everything).
+func targetVersion(arg string, migrations []migration, current int) (int, error) {
+	switch arg {
+	case "up":
+		if len(migrations) == 0 {
+			return 0, nil
+		}
+		return migrations[len(migrations)-1].version, nil
+	case "down":
+		target := 0
+		for _, m := range migrations {
+			if m.version < current {
+				target = m.version
+			}
+		}
+		return target, nil
+	}
+	v, err := strconv.Atoi(arg)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("migrate: want up, down or a version, got %q", arg)
+	}
+	if v == 0 {
+		return 0, nil
+	}
+	for _, m := range migrations {
+		if m.version == v {
+			return v, nil
+		}
+	}
+	return 0, fmt.Errorf("migrate: no migration with version %d", v)
+}
+
+// plan lists the migrations to run to get from current to target, in the
+// order to run them, and whether they run down.
+func plan(migrations []migration, current, target int) (steps []migration, down bool) {
+	if target >= current {
+		for _, m := range migrations {
+			if m.version > current && m.version <= target {
+				steps = append(steps, m)
+			}
+		}
+		return steps, false
+	}
+	for i := len(migrations) - 1; i >= 0; i-- {
+		if m := migrations[i]; m.version <= current && m.version > target {
+			steps = append(steps, m)
+		}
+	}
+	return steps, true
+}
+
+// migrator applies the embedded migrations to a database.
+type migrator struct {
+	db         *sql.DB
+	migrations []migration
+}
+
+func newMigrator(db *sql.DB) (*migrator, error) {
+	sub, err := fs.Sub(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	migrations, err := loadMigrations(sub)
+	if err != nil {
+		return nil, err
+	}
+	return &migrator{db: db, migrations: migrations}, nil
+}
+
+// migrate moves the schema to the version arg names; see targetVersion.
+// Each migration runs in its own transaction, so a failure leaves the
+// schema at the last one that succeeded.
+func (m *migrator) migrate(ctx context.Context, arg string) (err error) {
+	// Advisory locks belong to a session, so everything runs on one
+	// connection.
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockID); err != nil {
+		return fmt.Errorf("taking migration lock: %w", err)
+	}
+	defer func() {
+		// The lock is released with the session anyway, but the pool keeps
+		// the connection open.
+		_, unlockErr := conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLockID)
+		err = errors.Join(err, unlockErr)
+	}()
+
+	if _, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		name       TEXT NOT NULL,
+		checksum   TEXT NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`); err != nil {
+		return fmt.Errorf("creating schema_migrations: %w", err)
+	}
+
+	applied, err := readApplied(ctx, conn)
+	if err != nil {
+		return err
+	}
+	if err := verifyApplied(m.migrations, applied); err != nil {
+		return err
+	}
+	current := 0
+	if len(applied) > 0 {
+		current = applied[len(applied)-1].version
+	}
+	target, err := targetVersion(arg, m.migrations, current)
+	if err != nil {
+		return err
+	}
+
+	steps, down := plan(m.migrations, current, target)
+	for _, step := range steps {
+		if err := runMigration(ctx, conn, step, down); err != nil {
+			return err
+		}
+		direction := "up"
+		if down {
+			direction = "down"
+		}
+		slog.Info("migrated", "direction", direction, "version", step.version, "name", step.name)
+	}
+	return nil
+}
+
+func readApplied(ctx context.Context, conn *sql.Conn) ([]appliedMigration, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version, checksum FROM schema_migrations ORDER BY version`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var applied []appliedMigration
+	for rows.Next() {
+		var a appliedMigration
+		if err := rows.Scan(&a.version, &a.checksum); err != nil {
+			return nil, err
+		}
+		applied = append(applied, a)
+	}
+	return applied, rows.Err()
+}
+
+func runMigration(ctx context.Context, conn *sql.Conn, m migration, down bool) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	script, record := m.up, `INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)`
+	args := []any{m.version, m.name, m.checksum()}
+	if down {
+		script, record = m.down, `DELETE FROM schema_migrations WHERE version = $1`
+		args = args[:1]
+	}
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return fmt.Errorf("migration %s: %w", m, err)
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		return fmt.Errorf("migration %s: recording it: %w", m, err)
+	}
+	return tx.Commit()
+}
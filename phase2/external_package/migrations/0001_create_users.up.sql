@@ -0,0 +1,7 @@
+-- IF NOT EXISTS adopts a users table created before migrations were tracked.
+CREATE TABLE IF NOT EXISTS users (
+	id    SERIAL PRIMARY KEY,
+	name  TEXT NOT NULL,
+	email TEXT NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS users_email_key ON users (lower(email));
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func migrationFS(files ...string) fstest.MapFS {
+	fsys := fstest.MapFS{}
+	for _, name := range files {
+		fsys[name] = &fstest.MapFile{Data: []byte("-- " + name)}
+	}
+	return fsys
+}
+
+func TestLoadMigrations(t *testing.T) {
+	migrations, err := loadMigrations(migrationFS(
+		"0010_add_index.up.sql", "0010_add_index.down.sql",
+		"0002_create_users.up.sql", "0002_create_users.down.sql",
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) != 2 || migrations[0].String() != "0002_create_users" || migrations[1].String() != "0010_add_index" {
+		t.Fatalf("loadMigrations = %v; want 0002_create_users, 0010_add_index", migrations)
+	}
+	if m := migrations[0]; m.up != "-- 0002_create_users.up.sql" || m.down != "-- 0002_create_users.down.sql" {
+		t.Errorf("migration 2 = %+v; want its up and down files", m)
+	}
+
+	tests := []struct {
+		files []string
+		err   string
+	}{
+		{[]string{"0001_users.up.sql"}, "needs both an up and a down file"},
+		{[]string{"0001_users.up.sql", "0001_people.down.sql"}, "also named"},
+		{[]string{"users.up.sql"}, "name must be"},
+		{[]string{"0000_users.up.sql", "0000_users.down.sql"}, "versions start at 1"},
+	}
+	for _, tt := range tests {
+		if _, err := loadMigrations(migrationFS(tt.files...)); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("loadMigrations(%v) error = %v; want %q", tt.files, err, tt.err)
+		}
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	sub, err := fs.Sub(migrationFiles, "migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrations, err := loadMigrations(sub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) == 0 || migrations[0].version != 1 {
+		t.Errorf("embedded migrations = %v; want to start at version 1", migrations)
+	}
+}
+
+func testMigrations(versions ...int) []migration {
+	var migrations []migration
+	for _, v := range versions {
+		migrations = append(migrations, migration{version: v, name: fmt.Sprint("m", v), up: fmt.Sprint("up ", v), down: fmt.Sprint("down ", v)})
+	}
+	return migrations
+}
+
+func TestVerifyApplied(t *testing.T) {
+	migrations := testMigrations(1, 2, 3)
+	applied := func(versions ...int) []appliedMigration {
+		var out []appliedMigration
+		for _, v := range versions {
+			out = append(out, appliedMigration{version: v, checksum: migrations[v-1].checksum()})
+		}
+		return out
+	}
+
+	if err := verifyApplied(migrations, applied(1, 2)); err != nil {
+		t.Errorf("verifyApplied with a clean history = %v", err)
+	}
+
+	edited := applied(1, 2)
+	edited[1].checksum = "0000"
+	tests := []struct {
+		name    string
+		applied []appliedMigration
+		err     string
+	}{
+		{"edited", edited, "0002_m2 was edited"},
+		{"missing file", append(applied(1), appliedMigration{version: 7}), "migration 7 is applied but its files are missing"},
+		{"added below applied", applied(1, 3), "0002_m2 is older than applied version 3"},
+	}
+	for _, tt := range tests {
+		if err := verifyApplied(migrations, tt.applied); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: verifyApplied error = %v; want %q", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestMigrationPlan(t *testing.T) {
+	migrations := testMigrations(1, 2, 5)
+	tests := []struct {
+		arg      string
+		current  int
+		want     string
+		wantDown bool
+	}{
+		{"up", 0, "1,2,5", false},
+		{"up", 2, "5", false},
+		{"up", 5, "", false},
+		{"down", 5, "5", true},
+		{"down", 1, "1", true},
+		{"down", 0, "", true},
+		{"2", 0, "1,2", false},
+		{"2", 5, "5", true},
+		{"0", 5, "5,2,1", true},
+	}
+	for _, tt := range tests {
+		target, err := targetVersion(tt.arg, migrations, tt.current)
+		if err != nil {
+			t.Errorf("targetVersion(%q, %d) = %v", tt.arg, tt.current, err)
+			continue
+		}
+		steps, down := plan(migrations, tt.current, target)
+		var got []string
+		for _, s := range steps {
+			got = append(got, fmt.Sprint(s.version))
+		}
+		if strings.Join(got, ",") != tt.want || (len(steps) > 0 && down != tt.wantDown) {
+			t.Errorf("-migrate %s from %d = %v (down %v); want %s (down %v)", tt.arg, tt.current, got, down, tt.want, tt.wantDown)
+		}
+	}
+
+	for _, arg := range []string{"3", "-1", "sideways"} {
+		if _, err := targetVersion(arg, migrations, 0); err == nil {
+			t.Errorf("targetVersion(%q) should fail", arg)
+		}
+	}
+}
+
+// TestPostgresMigrate needs a scratch database; see postgresTestUsers.
+func TestPostgresMigrate(t *testing.T) {
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	ctx := context.Background()
+	db, err := openDB(ctx, url, defaultPool)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	m, err := newMigrator(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.migrate(ctx, "0"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Two instances starting together: the lock makes the second wait and
+	// then find nothing left to do.
+	errs := make(chan error, 2)
+	for range 2 {
+		go func() { errs <- m.migrate(ctx, "up") }()
+	}
+	for range 2 {
+		if err := <-errs; err != nil {
+			t.Errorf("concurrent migrate up = %v", err)
+		}
+	}
+
+	if _, err := db.ExecContext(ctx, `UPDATE schema_migrations SET checksum = 'edited' WHERE version = 1`); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.migrate(ctx, "up"); err == nil || !strings.Contains(err.Error(), "edited") {
+		t.Errorf("migrate after editing = %v; want a checksum error", err)
+	}
+	if _, err := db.ExecContext(ctx, `UPDATE schema_migrations SET checksum = $1 WHERE version = 1`, m.migrations[0].checksum()); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq" // PostgreSQL driver
+)
+
+// poolConfig sizes the database connection pool.
+type poolConfig struct {
+	MaxOpen     int
+	MaxIdle     int
+	MaxLifetime time.Duration
+	MaxIdleTime time.Duration
+}
+
+var defaultPool = poolConfig{
+	MaxOpen:     10,
+	MaxIdle:     5,
+	MaxLifetime: 30 * time.Minute,
+	MaxIdleTime: 5 * time.Minute,
+}
+
+// openDB opens a pool and checks the database is reachable, so a bad URL
+// fails at startup rather than on the first request.
+func openDB(ctx context.Context, url string, pool poolConfig) (*sql.DB, error) {
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(pool.MaxOpen)
+	db.SetMaxIdleConns(pool.MaxIdle)
+	db.SetConnMaxLifetime(pool.MaxLifetime)
+	db.SetConnMaxIdleTime(pool.MaxIdleTime)
+
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("connecting to database: %w", err)
+	}
+	return db, nil
+}
+
+// postgresUsers stores users in the users table.
+type postgresUsers struct {
+	db *sql.DB
+}
+
+func newPostgresUsers(db *sql.DB) *postgresUsers {
+	return &postgresUsers{db: db}
+}
+
+func (p *postgresUsers) List(ctx context.Context, q UserQuery) (UserPage, error) {
+	var (
+		where []string
+		args  []any
+	)
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	if q.Name != "" {
+		where = append(where, "strpos(lower(name), lower("+arg(q.Name)+")) > 0")
+	}
+	if q.Email != "" {
+		where = append(where, "lower(email) = lower("+arg(q.Email)+")")
+	}
+
+	var page UserPage
+	if q.Count {
+		err := p.db.QueryRowContext(ctx, `SELECT count(*) FROM users`+whereClause(where), args...).Scan(&page.Total)
+		if err != nil {
+			return UserPage{}, err
+		}
+	}
+
+	backward := q.Cursor != nil && q.Cursor.Backward
+	if q.Cursor != nil {
+		where = append(where, keysetCondition(q.Sort, q.Cursor.Key, backward, arg))
+	}
+	order := make([]string, len(q.Sort))
+	for i, s := range q.Sort {
+		order[i] = sortColumn(s.Field)
+		if s.Desc != backward {
+			order[i] += " DESC"
+		}
+	}
+	query := `SELECT id, name, email FROM users` + whereClause(where) +
+		` ORDER BY ` + strings.Join(order, ", ") +
+		` LIMIT ` + arg(q.Limit+1) + ` OFFSET ` + arg(q.Offset)
+
+	rows, err := p.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return UserPage{}, err
+	}
+	defer rows.Close()
+
+	page.Users = []User{}
+	for rows.Next() {
+		var u User
+		if err := rows.Scan(&u.ID, &u.Name, &u.Email); err != nil {
+			return UserPage{}, err
+		}
+		page.Users = append(page.Users, u)
+	}
+	if err := rows.Err(); err != nil {
+		return UserPage{}, err
+	}
+	if len(page.Users) > q.Limit {
+		page.Users, page.More = page.Users[:q.Limit], true
+	}
+	if backward {
+		slices.Reverse(page.Users)
+	}
+	return page, nil
+}
+
+// sortColumn is the ORDER BY expression for a whitelisted field. Text is
+// compared lowercased and bytewise, the same order memoryUsers uses.
+func sortColumn(field string) string {
+	if field == "id" {
+		return "id"
+	}
+	return "lower(" + field + `) COLLATE "C"`
+}
+
+// keysetCondition matches rows past key in sort order, or before it when
+// backward: (a > x) OR (a = x AND b > y) OR ...
+func keysetCondition(sort []SortField, key []string, backward bool, arg func(any) string) string {
+	var or []string
+	for i, s := range sort {
+		var and []string
+		for j := range i {
+			and = append(and, sortColumn(sort[j].Field)+" = "+keyValue(sort[j].Field, key[j], arg))
+		}
+		op := " > "
+		if s.Desc != backward {
+			op = " < "
+		}
+		and = append(and, sortColumn(s.Field)+op+keyValue(s.Field, key[i], arg))
+		or = append(or, "("+strings.Join(and, " AND ")+")")
+	}
+	return "(" + strings.Join(or, " OR ") + ")"
+}
+
+func keyValue(field, v string, arg func(any) string) string {
+	if field == "id" {
+		id, _ := strconv.Atoi(v)
+		return arg(id)
+	}
+	return "lower(" + arg(v) + `::text) COLLATE "C"`
+}
+
+func whereClause(conds []string) string {
+	if len(conds) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(conds, " AND ")
+}
+
+func (p *postgresUsers) Get(ctx context.Context, id int) (User, error) {
+	u := User{ID: id}
+	err := p.db.QueryRowContext(ctx, `SELECT name, email FROM users WHERE id = $1`, id).
+		Scan(&u.Name, &u.Email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return User{}, ErrUserNotFound
+	}
+	return u, err
+}
+
+func (p *postgresUsers) Create(ctx context.Context, u User) (User, error) {
+	err := p.db.QueryRowContext(ctx, `INSERT INTO users (name, email) VALUES ($1, $2) RETURNING id`,
+		u.Name, u.Email).Scan(&u.ID)
+	if err != nil {
+		return User{}, pqError(err)
+	}
+	return u, nil
+}
+
+func (p *postgresUsers) Update(ctx context.Context, u User) (User, error) {
+	res, err := p.db.ExecContext(ctx, `UPDATE users SET name = $2, email = $3 WHERE id = $1`,
+		u.ID, u.Name, u.Email)
+	if err != nil {
+		return User{}, pqError(err)
+	}
+	if err := requireRow(res); err != nil {
+		return User{}, err
+	}
+	return u, nil
+}
+
+func (p *postgresUsers) Delete(ctx context.Context, id int) error {
+	res, err := p.db.ExecContext(ctx, `DELETE FROM users WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	return requireRow(res)
+}
+
+func requireRow(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// pqError maps constraint violations onto repository errors.
+func pqError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "users_email_key" {
+		return ErrEmailTaken
+	}
+	return err
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+)
+
+const problemContentType = "application/problem+json"
+
+// problem is an RFC 7807 error body. Type is always about:blank, so Title
+// is the status text and Detail says what went wrong. RequestID and
+// TraceID let a client's bug report be matched with our logs.
+type problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Errors    []fieldError `json:"errors,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+	TraceID   string       `json:"trace_id,omitempty"`
+}
+
+// fieldError is one invalid field of a request body.
+type fieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func writeProblem(w http.ResponseWriter, r *http.Request, status int, detail string, errs ...fieldError) {
+	p := problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		Errors:    errs,
+		RequestID: requestIDFrom(r.Context()),
+		TraceID:   traceIDFrom(r.Context()),
+	}
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(p)
+}
+
+// writeRepoError maps repository errors onto problems. Anything unexpected
+// is logged and reported without detail, so internals don't leak.
+func writeRepoError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, ErrUserNotFound):
+		writeProblem(w, r, http.StatusNotFound, err.Error())
+	case errors.Is(err, ErrEmailTaken):
+		writeProblem(w, r, http.StatusConflict, err.Error(), fieldError{Field: "email", Message: err.Error()})
+	default:
+		slog.ErrorContext(r.Context(), "request failed", "method", r.Method, "path", r.URL.Path, "err", err)
+		writeProblem(w, r, http.StatusInternalServerError, "")
+	}
+}
+
+// notFound and methodNotAllowed replace the router's plain-text defaults.
+func notFound(w http.ResponseWriter, r *http.Request) {
+	writeProblem(w, r, http.StatusNotFound, "no such resource")
+}
+
+func methodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	writeProblem(w, r, http.StatusMethodNotAllowed, r.Method+" is not supported here")
+}
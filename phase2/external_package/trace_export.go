@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// spanExporter sends finished spans somewhere. The tracer calls it from a
+// single goroutine.
+type spanExporter interface {
+	exportSpans(ctx context.Context, spans []*span) error
+}
+
+// newExporter picks an exporter by name: none, stdout, or otlp (which
+// posts to endpoint).
+func newExporter(name, endpoint string, stdout io.Writer) (spanExporter, error) {
+	switch name {
+	case "none":
+		return nil, nil
+	case "stdout":
+		return &writerExporter{w: stdout}, nil
+	case "otlp":
+		if endpoint == "" {
+			return nil, fmt.Errorf("the otlp trace exporter needs an endpoint")
+		}
+		return &otlpExporter{url: strings.TrimSuffix(endpoint, "/") + "/v1/traces", client: &http.Client{}}, nil
+	}
+	return nil, fmt.Errorf("unknown trace exporter %q; use none, stdout or otlp", name)
+}
+
+// writerExporter writes one JSON object per span, for local debugging.
+type writerExporter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+type spanRecord struct {
+	Name     string         `json:"name"`
+	TraceID  string         `json:"trace_id"`
+	SpanID   string         `json:"span_id"`
+	ParentID string         `json:"parent_id,omitempty"`
+	Start    time.Time      `json:"start"`
+	Duration string         `json:"duration"`
+	Error    string         `json:"error,omitempty"`
+	Attrs    map[string]any `json:"attributes,omitempty"`
+}
+
+func (e *writerExporter) exportSpans(ctx context.Context, spans []*span) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	enc := json.NewEncoder(e.w)
+	for _, s := range spans {
+		rec := spanRecord{
+			Name:     s.name,
+			TraceID:  hex.EncodeToString(s.sc.TraceID[:]),
+			SpanID:   hex.EncodeToString(s.sc.SpanID[:]),
+			Start:    s.start,
+			Duration: s.end.Sub(s.start).String(),
+			Error:    s.err,
+		}
+		if s.parent != [8]byte{} {
+			rec.ParentID = hex.EncodeToString(s.parent[:])
+		}
+		if len(s.attrs) > 0 {
+			rec.Attrs = map[string]any{}
+			for _, a := range s.attrs {
+				rec.Attrs[a.Key] = a.Value
+			}
+		}
+		if err := enc.Encode(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// otlpExporter posts spans to an OpenTelemetry collector using OTLP over
+// HTTP with the JSON encoding.
+type otlpExporter struct {
+	url    string
+	client *http.Client
+}
+
+// The OTLP JSON shapes, trimmed to what we send. Ids are hex and 64-bit
+// integers are strings, as the OTLP JSON mapping requires.
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpAttr `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceID      string     `json:"traceId"`
+		SpanID       string     `json:"spanId"`
+		ParentSpanID string     `json:"parentSpanId,omitempty"`
+		Name         string     `json:"name"`
+		Kind         int        `json:"kind"`
+		Start        string     `json:"startTimeUnixNano"`
+		End          string     `json:"endTimeUnixNano"`
+		Attributes   []otlpAttr `json:"attributes,omitempty"`
+		Status       otlpStatus `json:"status"`
+	}
+	otlpAttr struct {
+		Key   string    `json:"key"`
+		Value otlpValue `json:"value"`
+	}
+	otlpValue struct {
+		StringValue *string `json:"stringValue,omitempty"`
+		IntValue    *string `json:"intValue,omitempty"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code"` // 0 unset, 2 error
+		Message string `json:"message,omitempty"`
+	}
+)
+
+const serviceName = "users-api"
+
+func otlpAttribute(key string, v any) otlpAttr {
+	a := otlpAttr{Key: key}
+	switch v := v.(type) {
+	case int:
+		s := strconv.Itoa(v)
+		a.Value.IntValue = &s
+	default:
+		s := fmt.Sprint(v)
+		a.Value.StringValue = &s
+	}
+	return a
+}
+
+func (e *otlpExporter) exportSpans(ctx context.Context, spans []*span) error {
+	scope := otlpScopeSpans{Scope: otlpScope{Name: "github.com/lasanthak/go-demo/phase2/external_package"}}
+	for _, s := range spans {
+		out := otlpSpan{
+			TraceID: hex.EncodeToString(s.sc.TraceID[:]),
+			SpanID:  hex.EncodeToString(s.sc.SpanID[:]),
+			Name:    s.name,
+			Kind:    s.kind,
+			Start:   strconv.FormatInt(s.start.UnixNano(), 10),
+			End:     strconv.FormatInt(s.end.UnixNano(), 10),
+		}
+		if s.parent != [8]byte{} {
+			out.ParentSpanID = hex.EncodeToString(s.parent[:])
+		}
+		for _, a := range s.attrs {
+			out.Attributes = append(out.Attributes, otlpAttribute(a.Key, a.Value))
+		}
+		if s.err != "" {
+			out.Status = otlpStatus{Code: 2, Message: s.err}
+		}
+		scope.Spans = append(scope.Spans, out)
+	}
+	body, err := json.Marshal(otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: []otlpAttr{otlpAttribute("service.name", serviceName)}},
+		ScopeSpans: []otlpScopeSpans{scope},
+	}}})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("collector answered %s", resp.Status)
+	}
+	return nil
+}
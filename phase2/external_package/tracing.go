@@ -0,0 +1,335 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// spanContext is the part of a span that crosses process boundaries, as
+// carried by a W3C traceparent header.
+type spanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	Sampled bool
+}
+
+func (sc spanContext) valid() bool {
+	return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+// traceparent formats sc as a version 00 traceparent header.
+func (sc spanContext) traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + hex.EncodeToString(sc.TraceID[:]) + "-" + hex.EncodeToString(sc.SpanID[:]) + "-" + flags
+}
+
+var traceparentRE = regexp.MustCompile(`^([0-9a-f]{2})-([0-9a-f]{32})-([0-9a-f]{16})-([0-9a-f]{2})(-.*)?$`)
+
+// parseTraceparent reads a traceparent header. Versions after 00 may add
+// fields, which are ignored; version ff and all-zero ids are invalid.
+func parseTraceparent(h string) (spanContext, bool) {
+	m := traceparentRE.FindStringSubmatch(h)
+	if m == nil || m[1] == "ff" || (m[1] == "00" && m[5] != "") {
+		return spanContext{}, false
+	}
+	var sc spanContext
+	hex.Decode(sc.TraceID[:], []byte(m[2]))
+	hex.Decode(sc.SpanID[:], []byte(m[3]))
+	flags, _ := strconv.ParseUint(m[4], 16, 8)
+	sc.Sampled = flags&1 == 1
+	return sc, sc.valid()
+}
+
+// spanKind values match OTLP's.
+const (
+	spanKindInternal = 1
+	spanKindServer   = 2
+)
+
+type spanAttr struct {
+	Key   string
+	Value any // string or int
+}
+
+// span is one timed operation. Spans from unsampled traces carry ids for
+// propagation but are never exported.
+type span struct {
+	tracer *tracer
+	name   string
+	kind   int
+	sc     spanContext
+	parent [8]byte
+	start  time.Time
+	end    time.Time
+	attrs  []spanAttr
+	err    string // set when the operation failed
+}
+
+func (s *span) setAttr(key string, value any) {
+	s.attrs = append(s.attrs, spanAttr{key, value})
+}
+
+// finish ends the span, marking it failed if err is non-nil, and queues it
+// for export.
+func (s *span) finish(err error) {
+	s.end = time.Now()
+	if err != nil {
+		s.err = err.Error()
+	}
+	if s.sc.Sampled {
+		s.tracer.enqueue(s)
+	}
+}
+
+// tracer starts spans and hands finished ones to an exporter in batches,
+// off the request path. With a nil exporter spans are only propagated.
+type tracer struct {
+	exporter spanExporter
+
+	mu     sync.RWMutex // guards closed against enqueue
+	closed bool
+	queue  chan *span
+	done   chan struct{}
+}
+
+const (
+	traceQueueSize = 2048
+	traceBatchSize = 256
+	traceFlushTime = time.Second
+)
+
+func newTracer(exporter spanExporter) *tracer {
+	t := &tracer{exporter: exporter, queue: make(chan *span, traceQueueSize), done: make(chan struct{})}
+	if exporter == nil {
+		close(t.done)
+		return t
+	}
+	go t.run()
+	return t
+}
+
+func (t *tracer) run() {
+	defer close(t.done)
+	var batch []*span
+	tick := time.NewTicker(traceFlushTime)
+	defer tick.Stop()
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := t.exporter.exportSpans(ctx, batch); err != nil {
+			slog.Warn("exporting spans", "spans", len(batch), "err", err)
+		}
+		batch = nil
+	}
+	for {
+		select {
+		case s, ok := <-t.queue:
+			if !ok {
+				flush()
+				return
+			}
+			if batch = append(batch, s); len(batch) >= traceBatchSize {
+				flush()
+			}
+		case <-tick.C:
+			flush()
+		}
+	}
+}
+
+// enqueue drops the span rather than block a request when the exporter
+// can't keep up.
+func (t *tracer) enqueue(s *span) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.closed || t.exporter == nil {
+		return
+	}
+	select {
+	case t.queue <- s:
+	default:
+	}
+}
+
+// shutdown exports the spans still queued, giving up when ctx is done.
+func (t *tracer) shutdown(ctx context.Context) error {
+	t.mu.Lock()
+	if !t.closed && t.exporter != nil {
+		t.closed = true
+		close(t.queue)
+	}
+	t.mu.Unlock()
+	select {
+	case <-t.done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("flushing spans: %w", ctx.Err())
+	}
+}
+
+func newID(b []byte) {
+	rand.Read(b)
+}
+
+// startSpan starts a span under parent. Without a valid parent it starts a
+// new, sampled trace.
+func (t *tracer) startSpan(ctx context.Context, name string, kind int, parent spanContext) (context.Context, *span) {
+	s := &span{tracer: t, name: name, kind: kind, start: time.Now()}
+	if parent.valid() {
+		s.sc.TraceID, s.sc.Sampled, s.parent = parent.TraceID, parent.Sampled, parent.SpanID
+	} else {
+		newID(s.sc.TraceID[:])
+		s.sc.Sampled = true
+	}
+	newID(s.sc.SpanID[:])
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// start starts an internal span under the one in ctx.
+func (t *tracer) start(ctx context.Context, name string) (context.Context, *span) {
+	var parent spanContext
+	if s := spanFrom(ctx); s != nil {
+		parent = s.sc
+	}
+	return t.startSpan(ctx, name, spanKindInternal, parent)
+}
+
+type (
+	spanKey      struct{}
+	requestIDKey struct{}
+)
+
+func spanFrom(ctx context.Context) *span {
+	s, _ := ctx.Value(spanKey{}).(*span)
+	return s
+}
+
+func requestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// traceIDFrom is the hex trace id for ctx's span, if any.
+func traceIDFrom(ctx context.Context) string {
+	if s := spanFrom(ctx); s != nil {
+		return hex.EncodeToString(s.sc.TraceID[:])
+	}
+	return ""
+}
+
+// requestIDRE is what we accept from a client's X-Request-ID; anything
+// else is replaced, so ids are safe to log and echo.
+var requestIDRE = regexp.MustCompile(`^[A-Za-z0-9._:/+=-]{1,128}$`)
+
+// middleware gives every request an id and a server span. The id comes
+// from X-Request-ID when the client sent a usable one, and the span joins
+// the client's trace when it sent a traceparent. Both go back out in the
+// response headers and into the request context, where the logger and
+// problem bodies find them. Probes aren't traced.
+func (t *tracer) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if quietPaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		id := r.Header.Get("X-Request-ID")
+		if !requestIDRE.MatchString(id) {
+			var b [16]byte
+			newID(b[:])
+			id = hex.EncodeToString(b[:])
+		}
+		parent, _ := parseTraceparent(r.Header.Get("traceparent"))
+		ctx, s := t.startSpan(context.WithValue(r.Context(), requestIDKey{}, id), r.Method, spanKindServer, parent)
+		w.Header().Set("X-Request-ID", id)
+		w.Header().Set("traceparent", s.sc.traceparent())
+
+		r, route := withRoute(r.WithContext(ctx))
+		rec := &responseRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+
+		if *route != "" {
+			s.name = r.Method + " " + *route
+			s.setAttr("http.route", *route)
+		}
+		s.setAttr("http.request.method", r.Method)
+		s.setAttr("url.path", r.URL.Path)
+		s.setAttr("http.response.status_code", rec.status)
+		s.setAttr("request_id", id)
+		var err error
+		if rec.status >= 500 {
+			err = errors.New(http.StatusText(rec.status))
+		}
+		s.finish(err)
+	})
+}
+
+// tracedUsers wraps a repository with a span per call. Not found and
+// conflict are answers rather than failures, so they don't mark the span.
+type tracedUsers struct {
+	next   UserRepository
+	tracer *tracer
+}
+
+func (t tracedUsers) trace(ctx context.Context, op string) (context.Context, func(error)) {
+	ctx, s := t.tracer.start(ctx, "users."+op)
+	return ctx, func(err error) {
+		if errors.Is(err, ErrUserNotFound) || errors.Is(err, ErrEmailTaken) {
+			s.setAttr("result", err.Error())
+			err = nil
+		}
+		s.finish(err)
+	}
+}
+
+func (t tracedUsers) List(ctx context.Context, q UserQuery) (UserPage, error) {
+	ctx, done := t.trace(ctx, "List")
+	page, err := t.next.List(ctx, q)
+	done(err)
+	return page, err
+}
+
+func (t tracedUsers) Get(ctx context.Context, id int) (User, error) {
+	ctx, done := t.trace(ctx, "Get")
+	u, err := t.next.Get(ctx, id)
+	done(err)
+	return u, err
+}
+
+func (t tracedUsers) Create(ctx context.Context, u User) (User, error) {
+	ctx, done := t.trace(ctx, "Create")
+	u, err := t.next.Create(ctx, u)
+	done(err)
+	return u, err
+}
+
+func (t tracedUsers) Update(ctx context.Context, u User) (User, error) {
+	ctx, done := t.trace(ctx, "Update")
+	u, err := t.next.Update(ctx, u)
+	done(err)
+	return u, err
+}
+
+func (t tracedUsers) Delete(ctx context.Context, id int) error {
+	ctx, done := t.trace(ctx, "Delete")
+	err := t.next.Delete(ctx, id)
+	done(err)
+	return err
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		header  string
+		ok      bool
+		sampled bool
+	}{
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true, true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", true, false},
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-future", true, true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", false, false},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false, false},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", false, false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false, false},
+		{"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", false, false},
+		{"", false, false},
+	}
+	for _, tt := range tests {
+		sc, ok := parseTraceparent(tt.header)
+		if ok != tt.ok || (ok && sc.Sampled != tt.sampled) {
+			t.Errorf("parseTraceparent(%q) = %v, sampled %v; want %v, sampled %v", tt.header, ok, sc.Sampled, tt.ok, tt.sampled)
+		}
+		if ok && strings.HasPrefix(tt.header, "00-") && sc.traceparent() != tt.header {
+			t.Errorf("traceparent() = %q; want %q back", sc.traceparent(), tt.header)
+		}
+	}
+}
+
+// standInCollector accepts OTLP/HTTP JSON the way a collector would and
+// keeps the spans it was sent.
+type standInCollector struct {
+	mu    sync.Mutex
+	spans []otlpSpan
+}
+
+func (c *standInCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req otlpRequest
+	if r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/json" || json.NewDecoder(r.Body).Decode(&req) != nil {
+		http.Error(w, "bad export", http.StatusBadRequest)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, rs := range req.ResourceSpans {
+		for _, ss := range rs.ScopeSpans {
+			c.spans = append(c.spans, ss.Spans...)
+		}
+	}
+	w.Write([]byte("{}"))
+}
+
+func (c *standInCollector) span(name string) (otlpSpan, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, s := range c.spans {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return otlpSpan{}, false
+}
+
+func TestTracing(t *testing.T) {
+	collector := &standInCollector{}
+	collectorSrv := httptest.NewServer(collector)
+	defer collectorSrv.Close()
+	exporter, err := newExporter("otlp", collectorSrv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracer := newTracer(exporter)
+	var logBuf bytes.Buffer
+	logger, _ := newLogger("json", &logBuf)
+	a := &api{users: tracedUsers{next: newMemoryUsers(), tracer: tracer}}
+	srv := httptest.NewServer(newHandler(a, newAccessLog(logger, 1), tracer))
+	defer srv.Close()
+
+	const (
+		incoming = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+		traceID  = "4bf92f3577b34da6a3ce929d0e0e4736"
+	)
+	req, _ := http.NewRequest("GET", srv.URL+usersPath+"/7", nil)
+	req.Header.Set("traceparent", incoming)
+	req.Header.Set("X-Request-ID", "client-req-42")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body bytes.Buffer
+	body.ReadFrom(resp.Body)
+	resp.Body.Close()
+
+	if id := resp.Header.Get("X-Request-ID"); id != "client-req-42" {
+		t.Errorf("X-Request-ID = %q; want the client's", id)
+	}
+	out, ok := parseTraceparent(resp.Header.Get("traceparent"))
+	if !ok || !strings.Contains(resp.Header.Get("traceparent"), traceID) || out.traceparent() == incoming {
+		t.Errorf("traceparent = %q; want a new span in trace %s", resp.Header.Get("traceparent"), traceID)
+	}
+	p := decodeProblem(t, resp, body.String())
+	if p.RequestID != "client-req-42" || p.TraceID != traceID {
+		t.Errorf("problem ids = %q, %q; want client-req-42, %s", p.RequestID, p.TraceID, traceID)
+	}
+	lines := logLines(t, &logBuf)
+	if len(lines) != 1 || lines[0]["request_id"] != "client-req-42" || lines[0]["trace_id"] != traceID {
+		t.Errorf("access log = %v; want one line with the request and trace ids", lines)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := tracer.shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	server, ok := collector.span("GET /api/v1/users/{id:[0-9]+}")
+	if !ok {
+		t.Fatalf("collector got %+v; want the server span", collector.spans)
+	}
+	if server.TraceID != traceID || server.ParentSpanID != "00f067aa0ba902b7" || server.Kind != spanKindServer || server.Status.Code != 0 {
+		t.Errorf("server span = %+v; want a child of the incoming span", server)
+	}
+	repo, ok := collector.span("users.Get")
+	if !ok || repo.TraceID != traceID || repo.ParentSpanID != server.SpanID || repo.Kind != spanKindInternal {
+		t.Errorf("repository span = %+v (found %v); want a child of the server span", repo, ok)
+	}
+}
+
+func TestRequestIDs(t *testing.T) {
+	srv := httptest.NewServer(newTracer(nil).middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(requestIDFrom(r.Context()) + " " + traceIDFrom(r.Context())))
+	})))
+	defer srv.Close()
+
+	seen := map[string]bool{}
+	for _, sent := range []string{"", "has spaces", strings.Repeat("x", 200), "<script>"} {
+		req, _ := http.NewRequest("GET", srv.URL, nil)
+		req.Header.Set("X-Request-ID", sent)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var body bytes.Buffer
+		body.ReadFrom(resp.Body)
+		resp.Body.Close()
+		id, trace, _ := strings.Cut(body.String(), " ")
+		if len(id) != 32 || id != resp.Header.Get("X-Request-ID") || seen[id] {
+			t.Errorf("sent X-Request-ID %q: got id %q (header %q); want a fresh generated one", sent, id, resp.Header.Get("X-Request-ID"))
+		}
+		seen[id] = true
+		if len(trace) != 32 {
+			t.Errorf("trace id = %q; want a new trace", trace)
+		}
+	}
+}
+
+func TestStdoutExporter(t *testing.T) {
+	var buf bytes.Buffer
+	exporter, err := newExporter("stdout", "", &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracer := newTracer(exporter)
+	ctx, parent := tracer.start(context.Background(), "parent")
+	_, child := tracer.start(ctx, "child")
+	child.setAttr("rows", 3)
+	child.finish(errors.New("boom"))
+	parent.finish(nil)
+	if err := tracer.shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	var recs []spanRecord
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var rec spanRecord
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("decoding %q: %v", line, err)
+		}
+		recs = append(recs, rec)
+	}
+	if len(recs) != 2 || recs[0].Name != "child" || recs[0].Error != "boom" || recs[0].Attrs["rows"] != float64(3) {
+		t.Fatalf("exported %+v; want child then parent", recs)
+	}
+	if recs[0].ParentID != recs[1].SpanID || recs[0].TraceID != recs[1].TraceID {
+		t.Errorf("child %+v is not under parent %+v", recs[0], recs[1])
+	}
+
+	for _, name := range []string{"zipkin", "otlp"} {
+		if _, err := newExporter(name, "", nil); err == nil {
+			t.Errorf("newExporter(%q, no endpoint) should fail", name)
+		}
+	}
+}
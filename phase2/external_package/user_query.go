@@ -0,0 +1,225 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// sortableFields are the fields ?sort= accepts. Names and emails sort
+// case-insensitively.
+var sortableFields = []string{"id", "name", "email"}
+
+// UserQuery selects a page of users. Pages are either offset-based
+// (Offset) or keyset-based (Cursor), never both.
+type UserQuery struct {
+	Name  string // case-insensitive substring of the name
+	Email string // case-insensitive exact email
+	// Sort always ends with id, so the order is total and a cursor is an
+	// exact position.
+	Sort   []SortField
+	Limit  int
+	Offset int
+	Cursor *Cursor
+	Count  bool // fill in UserPage.Total
+}
+
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// Cursor is a position between two users: just after the user whose sort
+// key is Key, or just before it when Backward.
+type Cursor struct {
+	Key      []string
+	Backward bool
+}
+
+// UserPage is one page of a list. More says whether there are users past
+// the page in the direction it was read.
+type UserPage struct {
+	Users []User
+	More  bool
+	Total int
+}
+
+// sortKey is u's value for each sort field, as a cursor stores it.
+func sortKey(u User, sort []SortField) []string {
+	key := make([]string, len(sort))
+	for i, s := range sort {
+		switch s.Field {
+		case "id":
+			key[i] = strconv.Itoa(u.ID)
+		case "name":
+			key[i] = u.Name
+		case "email":
+			key[i] = u.Email
+		}
+	}
+	return key
+}
+
+// keyUser turns a cursor key back into a user, so it can be compared with
+// compareUsers.
+func keyUser(key []string, sort []SortField) User {
+	var u User
+	for i, s := range sort {
+		switch s.Field {
+		case "id":
+			u.ID, _ = strconv.Atoi(key[i])
+		case "name":
+			u.Name = key[i]
+		case "email":
+			u.Email = key[i]
+		}
+	}
+	return u
+}
+
+// compareUsers orders users by sort.
+func compareUsers(a, b User, sort []SortField) int {
+	for _, s := range sort {
+		var c int
+		switch s.Field {
+		case "id":
+			c = a.ID - b.ID
+		case "name":
+			c = strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+		case "email":
+			c = strings.Compare(strings.ToLower(a.Email), strings.ToLower(b.Email))
+		}
+		if s.Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+func formatSort(sort []SortField) string {
+	parts := make([]string, len(sort))
+	for i, s := range sort {
+		parts[i] = s.Field
+		if s.Desc {
+			parts[i] = "-" + s.Field
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// parseSort reads ?sort=name,-id and adds the id tiebreaker.
+func parseSort(spec string) ([]SortField, error) {
+	var sort []SortField
+	seen := map[string]bool{}
+	for _, part := range strings.Split(spec, ",") {
+		if part == "" {
+			continue
+		}
+		f := SortField{Field: strings.TrimPrefix(part, "-"), Desc: strings.HasPrefix(part, "-")}
+		if !slices.Contains(sortableFields, f.Field) {
+			return nil, fmt.Errorf("cannot sort by %q; use %s", f.Field, strings.Join(sortableFields, ", "))
+		}
+		if seen[f.Field] {
+			return nil, fmt.Errorf("%s is listed more than once", f.Field)
+		}
+		seen[f.Field] = true
+		sort = append(sort, f)
+	}
+	if !seen["id"] {
+		sort = append(sort, SortField{Field: "id"})
+	}
+	return sort, nil
+}
+
+// cursorToken is a cursor as it appears in ?cursor=. It records the sort
+// it was made for, since a key means nothing in another order.
+type cursorToken struct {
+	Sort     string   `json:"s"`
+	Key      []string `json:"k"`
+	Backward bool     `json:"b,omitempty"`
+}
+
+func encodeCursor(sort []SortField, c Cursor) string {
+	data, _ := json.Marshal(cursorToken{Sort: formatSort(sort), Key: c.Key, Backward: c.Backward})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string, sort []SortField) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	var t cursorToken
+	if err == nil {
+		err = json.Unmarshal(data, &t)
+	}
+	if err != nil || len(t.Key) != len(sort) {
+		return nil, fmt.Errorf("is not a cursor from this API")
+	}
+	if t.Sort != formatSort(sort) {
+		return nil, fmt.Errorf("was issued for sort=%s; keep the same sort while paging", t.Sort)
+	}
+	for i, s := range sort {
+		if _, err := strconv.Atoi(t.Key[i]); s.Field == "id" && err != nil {
+			return nil, fmt.Errorf("is not a cursor from this API")
+		}
+	}
+	return &Cursor{Key: t.Key, Backward: t.Backward}, nil
+}
+
+// parseUserQuery reads the list parameters, reporting each bad one.
+func parseUserQuery(v url.Values) (UserQuery, []fieldError) {
+	q := UserQuery{Name: v.Get("name"), Email: v.Get("email"), Limit: defaultPageSize}
+	var errs []fieldError
+	bad := func(param, msg string) {
+		errs = append(errs, fieldError{Field: param, Message: msg})
+	}
+
+	sort, err := parseSort(v.Get("sort"))
+	if err != nil {
+		bad("sort", err.Error())
+	}
+	q.Sort = sort
+
+	if s := v.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > maxPageSize {
+			bad("limit", fmt.Sprintf("must be a number from 1 to %d", maxPageSize))
+		}
+		q.Limit = n
+	}
+	if s := v.Get("offset"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			bad("offset", "must be a number, 0 or more")
+		}
+		q.Offset = n
+	}
+	if s := v.Get("count"); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			bad("count", "must be true or false")
+		}
+		q.Count = b
+	}
+	if s := v.Get("cursor"); s != "" && sort != nil {
+		switch c, err := decodeCursor(s, sort); {
+		case err != nil:
+			bad("cursor", err.Error())
+		case v.Has("offset"):
+			bad("cursor", "cannot be combined with offset")
+		default:
+			q.Cursor = c
+		}
+	}
+	return q, errs
+}
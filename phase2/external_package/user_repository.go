@@ -0,0 +1,24 @@
+package main
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	ErrUserNotFound = errors.New("user not found")
+	ErrEmailTaken   = errors.New("email already in use")
+)
+
+// UserRepository stores users. Implementations return ErrUserNotFound for
+// a missing id and ErrEmailTaken when an email belongs to another user.
+type UserRepository interface {
+	// List returns the page of users q selects.
+	List(ctx context.Context, q UserQuery) (UserPage, error)
+	Get(ctx context.Context, id int) (User, error)
+	// Create assigns the new user's ID.
+	Create(ctx context.Context, u User) (User, error)
+	// Update replaces the user with u.ID.
+	Update(ctx context.Context, u User) (User, error)
+	Delete(ctx context.Context, id int) error
+}
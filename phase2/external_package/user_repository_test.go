@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+// testUserRepository checks the behaviour every UserRepository shares.
+// repo must start empty.
+func testUserRepository(t *testing.T, repo UserRepository) {
+	ctx := context.Background()
+
+	alice, err := repo.Create(ctx, User{Name: "Alice", Email: "alice@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := repo.Create(ctx, User{Name: "Bob", Email: "bob@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alice.ID == 0 || bob.ID == alice.ID {
+		t.Fatalf("Create assigned ids %d and %d; want distinct non-zero ids", alice.ID, bob.ID)
+	}
+
+	if _, err := repo.Create(ctx, User{Name: "Alice 2", Email: "Alice@Example.com"}); !errors.Is(err, ErrEmailTaken) {
+		t.Errorf("Create with a taken email = %v; want ErrEmailTaken", err)
+	}
+
+	if got, err := repo.Get(ctx, alice.ID); err != nil || got != alice {
+		t.Errorf("Get(%d) = %+v, %v; want %+v", alice.ID, got, err, alice)
+	}
+	if _, err := repo.Get(ctx, bob.ID+100); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("Get of a missing id = %v; want ErrUserNotFound", err)
+	}
+
+	bob.Name = "Robert"
+	if got, err := repo.Update(ctx, bob); err != nil || got != bob {
+		t.Errorf("Update = %+v, %v; want %+v", got, err, bob)
+	}
+	// Keeping your own email is not a conflict.
+	if _, err := repo.Update(ctx, bob); err != nil {
+		t.Errorf("Update with an unchanged email = %v", err)
+	}
+	if _, err := repo.Update(ctx, User{ID: bob.ID, Name: "Bob", Email: alice.Email}); !errors.Is(err, ErrEmailTaken) {
+		t.Errorf("Update to a taken email = %v; want ErrEmailTaken", err)
+	}
+	if _, err := repo.Update(ctx, User{ID: bob.ID + 100, Name: "Nobody", Email: "nobody@example.com"}); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("Update of a missing id = %v; want ErrUserNotFound", err)
+	}
+
+	page, err := repo.List(ctx, UserQuery{Sort: []SortField{{Field: "id"}}, Limit: 10})
+	if list := page.Users; err != nil || len(list) != 2 || list[0] != alice || list[1] != bob {
+		t.Errorf("List = %+v, %v; want [%+v %+v]", list, err, alice, bob)
+	}
+
+	if err := repo.Delete(ctx, alice.ID); err != nil {
+		t.Errorf("Delete = %v", err)
+	}
+	if err := repo.Delete(ctx, alice.ID); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("second Delete = %v; want ErrUserNotFound", err)
+	}
+	// The email is free again once its user is gone.
+	if _, err := repo.Create(ctx, User{Name: "Alice", Email: alice.Email}); err != nil {
+		t.Errorf("Create with a freed email = %v", err)
+	}
+}
+
+// testUserQueries checks filtering, sorting and paging. repo must start
+// empty.
+func testUserQueries(t *testing.T, repo UserRepository) {
+	ctx := context.Background()
+	ids := map[string]int{}
+	for _, name := range []string{"alice", "Bob", "carol", "Dave", "bob"} {
+		u, err := repo.Create(ctx, User{Name: name, Email: strings.ToLower(name) + fmt.Sprint(len(ids)) + "@example.com"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[name] = u.ID
+	}
+	names := func(users []User) string {
+		var out []string
+		for _, u := range users {
+			out = append(out, u.Name)
+		}
+		return strings.Join(out, ",")
+	}
+	byName, _ := parseSort("name,-id")
+
+	tests := []struct {
+		desc      string
+		q         UserQuery
+		want      string
+		wantMore  bool
+		wantTotal int
+	}{
+		{"first page", UserQuery{Limit: 2}, "alice,Bob", true, 0},
+		{"offset", UserQuery{Limit: 2, Offset: 4}, "bob", false, 0},
+		{"past the end", UserQuery{Limit: 2, Offset: 9}, "", false, 0},
+		{"name filter", UserQuery{Name: "BO", Limit: 10, Count: true}, "Bob,bob", false, 2},
+		{"email filter", UserQuery{Email: "DAVE3@example.com", Limit: 10}, "Dave", false, 0},
+		{"sort", UserQuery{Sort: byName, Limit: 10}, "alice,bob,Bob,carol,Dave", false, 0},
+		{"count ignores paging", UserQuery{Limit: 1, Offset: 1, Count: true}, "Bob", true, 5},
+		{"after cursor", UserQuery{Sort: byName, Limit: 2, Cursor: &Cursor{Key: []string{"bob", fmt.Sprint(ids["bob"])}}},
+			"Bob,carol", true, 0},
+		{"before cursor", UserQuery{Sort: byName, Limit: 2, Cursor: &Cursor{Key: []string{"carol", fmt.Sprint(ids["carol"])}, Backward: true}},
+			"bob,Bob", true, 0},
+	}
+	for _, tt := range tests {
+		if tt.q.Sort == nil {
+			tt.q.Sort = []SortField{{Field: "id"}}
+		}
+		page, err := repo.List(ctx, tt.q)
+		if err != nil {
+			t.Errorf("%s: List = %v", tt.desc, err)
+			continue
+		}
+		if got := names(page.Users); got != tt.want || page.More != tt.wantMore || (tt.q.Count && page.Total != tt.wantTotal) {
+			t.Errorf("%s: List = %s (more %v, total %d); want %s (more %v, total %d)",
+				tt.desc, got, page.More, page.Total, tt.want, tt.wantMore, tt.wantTotal)
+		}
+	}
+}
+
+func TestMemoryUsers(t *testing.T) {
+	testUserRepository(t, newMemoryUsers())
+	testUserQueries(t, newMemoryUsers())
+}
+
+// postgresTestUsers needs a scratch database, e.g.
+// TEST_DATABASE_URL=postgres://postgres@localhost/test?sslmode=disable.
+// It rolls back every migration first, dropping the users table.
+func postgresTestUsers(t *testing.T) UserRepository {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	ctx := context.Background()
+	db, err := openDB(ctx, url, defaultPool)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	m, err := newMigrator(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.migrate(ctx, "0"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.migrate(ctx, "up"); err != nil {
+		t.Fatal(err)
+	}
+	return newPostgresUsers(db)
+}
+
+func TestPostgresUsers(t *testing.T) {
+	testUserRepository(t, postgresTestUsers(t))
+	testUserQueries(t, postgresTestUsers(t))
+}
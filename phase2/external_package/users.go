@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gorilla/mux"
+)
+
+const usersPath = "/api/v1/users"
+
+// api serves the users endpoints from a repository.
+type api struct {
+	users        UserRepository
+	auth         *authenticator // nil leaves the API open
+	checks       []healthCheck  // for /readyz
+	shuttingDown atomic.Bool
+}
+
+// userPatch is a PATCH body; fields left out keep their current value.
+type userPatch struct {
+	Name  *string `json:"name"`
+	Email *string `json:"email"`
+}
+
+// handleUsers lists users a page at a time. The body stays a plain array;
+// paging links go in the Link header and the total in X-Total-Count.
+func (a *api) handleUsers(w http.ResponseWriter, r *http.Request) {
+	q, errs := parseUserQuery(r.URL.Query())
+	if len(errs) > 0 {
+		writeProblem(w, r, http.StatusBadRequest, "invalid query parameters", errs...)
+		return
+	}
+	page, err := a.users.List(r.Context(), q)
+	if err != nil {
+		writeRepoError(w, r, err)
+		return
+	}
+	if links := pageLinks(r.URL, q, page); len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+	if q.Count {
+		w.Header().Set("X-Total-Count", strconv.Itoa(page.Total))
+	}
+	writeJSON(w, http.StatusOK, page.Users)
+}
+
+// pageLinks builds the next and prev links for page, keeping the request's
+// other parameters. A request with ?offset= pages by offset; any other
+// pages by cursor.
+func pageLinks(u *url.URL, q UserQuery, page UserPage) []string {
+	var links []string
+	link := func(rel string, set func(url.Values)) {
+		v := u.Query()
+		set(v)
+		links = append(links, fmt.Sprintf(`<%s?%s>; rel="%s"`, u.Path, v.Encode(), rel))
+	}
+
+	if q.Cursor == nil && u.Query().Has("offset") {
+		if page.More {
+			link("next", func(v url.Values) { v.Set("offset", strconv.Itoa(q.Offset+q.Limit)) })
+		}
+		if q.Offset > 0 {
+			link("prev", func(v url.Values) { v.Set("offset", strconv.Itoa(max(q.Offset-q.Limit, 0))) })
+		}
+		return links
+	}
+
+	if len(page.Users) == 0 {
+		return nil
+	}
+	backward := q.Cursor != nil && q.Cursor.Backward
+	cursor := func(u User, backward bool) func(url.Values) {
+		return func(v url.Values) {
+			v.Set("cursor", encodeCursor(q.Sort, Cursor{Key: sortKey(u, q.Sort), Backward: backward}))
+		}
+	}
+	// More is about the direction we read in; a cursor means there is a
+	// page back the way we came.
+	hasNext, hasPrev := page.More, q.Cursor != nil
+	if backward {
+		hasNext, hasPrev = true, page.More
+	}
+	if hasNext {
+		link("next", cursor(page.Users[len(page.Users)-1], false))
+	}
+	if hasPrev {
+		link("prev", cursor(page.Users[0], true))
+	}
+	return links
+}
+
+func (a *api) handleCreateUser(w http.ResponseWriter, r *http.Request) {
+	var u User
+	if !decodeJSON(w, r, &u) {
+		return
+	}
+	u.ID = 0
+	u.normalize()
+	if !validateUser(w, r, u) {
+		return
+	}
+
+	u, err := a.users.Create(r.Context(), u)
+	if err != nil {
+		writeRepoError(w, r, err)
+		return
+	}
+	w.Header().Set("Location", usersPath+"/"+strconv.Itoa(u.ID))
+	writeJSON(w, http.StatusCreated, u)
+}
+
+func (a *api) handleUser(w http.ResponseWriter, r *http.Request) {
+	u, err := a.users.Get(r.Context(), userID(r))
+	if err != nil {
+		writeRepoError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, u)
+}
+
+// handleReplaceUser is PUT: the body is the whole user.
+func (a *api) handleReplaceUser(w http.ResponseWriter, r *http.Request) {
+	var u User
+	if !decodeJSON(w, r, &u) {
+		return
+	}
+	u.ID = userID(r)
+	u.normalize()
+	if !validateUser(w, r, u) {
+		return
+	}
+
+	u, err := a.users.Update(r.Context(), u)
+	if err != nil {
+		writeRepoError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, u)
+}
+
+// handlePatchUser is PATCH: only the fields in the body change.
+func (a *api) handlePatchUser(w http.ResponseWriter, r *http.Request) {
+	var p userPatch
+	if !decodeJSON(w, r, &p) {
+		return
+	}
+
+	u, err := a.users.Get(r.Context(), userID(r))
+	if err != nil {
+		writeRepoError(w, r, err)
+		return
+	}
+	if p.Name != nil {
+		u.Name = *p.Name
+	}
+	if p.Email != nil {
+		u.Email = *p.Email
+	}
+	u.normalize()
+	if !validateUser(w, r, u) {
+		return
+	}
+
+	u, err = a.users.Update(r.Context(), u)
+	if err != nil {
+		writeRepoError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, u)
+}
+
+func (a *api) handleDeleteUser(w http.ResponseWriter, r *http.Request) {
+	if err := a.users.Delete(r.Context(), userID(r)); err != nil {
+		writeRepoError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// userID is the {id} path variable; the route only matches digits.
+func userID(r *http.Request) int {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	return id
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
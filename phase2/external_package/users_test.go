@@ -0,0 +1,227 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// testAdminKey is an admin API key the test server accepts; do sends it.
+const testAdminKey = "test-admin-key-0123456789"
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	auth := testAuthenticator(t, "admin admin - "+testAdminKey)
+	srv := httptest.NewServer(newRouter(&api{users: newMemoryUsers(), auth: auth}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// do sends a request as an admin and returns the response with its body
+// read.
+func do(t *testing.T, method, url, body string) (*http.Response, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-API-Key", testAdminKey)
+	return send(t, req)
+}
+
+// send sends req as is.
+func send(t *testing.T, req *http.Request) (*http.Response, string) {
+	t.Helper()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, string(data)
+}
+
+func decodeUser(t *testing.T, body string) User {
+	t.Helper()
+	var u User
+	if err := json.Unmarshal([]byte(body), &u); err != nil {
+		t.Fatalf("decoding %q: %v", body, err)
+	}
+	return u
+}
+
+func TestUserCRUD(t *testing.T) {
+	srv := newTestServer(t)
+	users := srv.URL + usersPath
+
+	resp, body := do(t, "POST", users, `{"name":"Alice","email":"alice@example.com"}`)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create status = %d; want 201 (%s)", resp.StatusCode, body)
+	}
+	alice := decodeUser(t, body)
+	if loc := resp.Header.Get("Location"); loc != "/api/v1/users/1" {
+		t.Errorf("Location = %q; want /api/v1/users/1", loc)
+	}
+
+	resp, body = do(t, "GET", srv.URL+resp.Header.Get("Location"), "")
+	if resp.StatusCode != http.StatusOK || decodeUser(t, body) != alice {
+		t.Errorf("get = %d %s; want 200 %+v", resp.StatusCode, body, alice)
+	}
+
+	resp, body = do(t, "PUT", users+"/1", `{"name":"Alice Smith","email":"alice@example.org"}`)
+	want := User{ID: 1, Name: "Alice Smith", Email: "alice@example.org"}
+	if resp.StatusCode != http.StatusOK || decodeUser(t, body) != want {
+		t.Errorf("put = %d %s; want 200 %+v", resp.StatusCode, body, want)
+	}
+
+	resp, body = do(t, "PATCH", users+"/1", `{"name":"Al"}`)
+	want.Name = "Al"
+	if resp.StatusCode != http.StatusOK || decodeUser(t, body) != want {
+		t.Errorf("patch = %d %s; want 200 %+v", resp.StatusCode, body, want)
+	}
+
+	resp, body = do(t, "GET", users, "")
+	var list []User
+	if err := json.Unmarshal([]byte(body), &list); err != nil || len(list) != 1 || list[0] != want {
+		t.Errorf("list = %d %s; want [%+v]", resp.StatusCode, body, want)
+	}
+
+	if resp, _ = do(t, "DELETE", users+"/1", ""); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("delete status = %d; want 204", resp.StatusCode)
+	}
+	if resp, _ = do(t, "GET", users+"/1", ""); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("get after delete status = %d; want 404", resp.StatusCode)
+	}
+}
+
+func TestUserErrors(t *testing.T) {
+	srv := newTestServer(t)
+	users := srv.URL + usersPath
+	do(t, "POST", users, `{"name":"Alice","email":"alice@example.com"}`)
+	do(t, "POST", users, `{"name":"Bob","email":"bob@example.com"}`)
+
+	tests := []struct {
+		method, path, body string
+		status             int
+	}{
+		{"POST", "", `{"name":"Alice 2","email":"ALICE@example.com"}`, http.StatusConflict},
+		{"PUT", "/2", `{"name":"Bob","email":"alice@example.com"}`, http.StatusConflict},
+		{"PATCH", "/2", `{"email":"alice@example.com"}`, http.StatusConflict},
+		{"GET", "/9", "", http.StatusNotFound},
+		{"PUT", "/9", `{"name":"Nobody","email":"nobody@example.com"}`, http.StatusNotFound},
+		{"PATCH", "/9", `{"name":"Nobody"}`, http.StatusNotFound},
+		{"DELETE", "/9", "", http.StatusNotFound},
+		{"POST", "", `{"name":`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		resp, body := do(t, tt.method, users+tt.path, tt.body)
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s %s = %d; want %d (%s)", tt.method, tt.path, resp.StatusCode, tt.status, body)
+		}
+	}
+}
+
+// linkRE picks the target of one rel out of a Link header.
+var linkRE = regexp.MustCompile(`<([^>]*)>; rel="(\w+)"`)
+
+func links(resp *http.Response) map[string]string {
+	out := map[string]string{}
+	for _, m := range linkRE.FindAllStringSubmatch(resp.Header.Get("Link"), -1) {
+		out[m[2]] = m[1]
+	}
+	return out
+}
+
+func TestUserPaging(t *testing.T) {
+	srv := newTestServer(t)
+	users := srv.URL + usersPath
+	for _, name := range []string{"Erin", "alice", "Dave", "bob", "Carol"} {
+		do(t, "POST", users, fmt.Sprintf(`{"name":%q,"email":"%s@example.com"}`, name, strings.ToLower(name)))
+	}
+	get := func(path string) (*http.Response, string) {
+		t.Helper()
+		resp, body := do(t, "GET", srv.URL+path, "")
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("GET %s = %d %s", path, resp.StatusCode, body)
+		}
+		var list []User
+		if err := json.Unmarshal([]byte(body), &list); err != nil {
+			t.Fatalf("decoding %q: %v", body, err)
+		}
+		var names []string
+		for _, u := range list {
+			names = append(names, u.Name)
+		}
+		return resp, strings.Join(names, ",")
+	}
+
+	// Walk forward by cursor, then back again from the last page.
+	var pages []string
+	path := usersPath + "?sort=-name&limit=2&count=true"
+	resp, names := get(path)
+	if total := resp.Header.Get("X-Total-Count"); total != "5" {
+		t.Errorf("X-Total-Count = %q; want 5", total)
+	}
+	if _, ok := links(resp)["prev"]; ok {
+		t.Errorf("first page has a prev link: %s", resp.Header.Get("Link"))
+	}
+	for {
+		pages = append(pages, names)
+		next, ok := links(resp)["next"]
+		if !ok {
+			break
+		}
+		resp, names = get(next)
+	}
+	if got := strings.Join(pages, "|"); got != "Erin,Dave|Carol,bob|alice" {
+		t.Errorf("pages forward = %s; want Erin,Dave|Carol,bob|alice", got)
+	}
+	for i := len(pages) - 2; i >= 0; i-- {
+		prev, ok := links(resp)["prev"]
+		if !ok {
+			t.Fatalf("page %d has no prev link", i+2)
+		}
+		if resp, names = get(prev); names != pages[i] {
+			t.Errorf("prev to page %d = %s; want %s", i+1, names, pages[i])
+		}
+	}
+	if _, ok := links(resp)["prev"]; ok {
+		t.Errorf("first page, reached backwards, has a prev link: %s", resp.Header.Get("Link"))
+	}
+
+	resp, names = get(usersPath + "?limit=1&offset=1&name=E")
+	l := links(resp)
+	if names != "alice" || !strings.Contains(l["prev"], "offset=0") || !strings.Contains(l["next"], "offset=2") || !strings.Contains(l["next"], "name=E") {
+		t.Errorf("offset page = %s, links %v; want alice with prev offset=0 and next offset=2", names, l)
+	}
+	if _, names = get(usersPath + "?email=BOB@example.com"); names != "bob" {
+		t.Errorf("email filter = %s; want bob", names)
+	}
+
+	for _, query := range []string{"sort=age", "sort=name,name", "limit=0", "limit=101", "offset=-1", "count=maybe", "cursor=junk"} {
+		resp, body := do(t, "GET", users+"?"+query, "")
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("?%s = %d; want 400", query, resp.StatusCode)
+			continue
+		}
+		param, _, _ := strings.Cut(query, "=")
+		if p := decodeProblem(t, resp, body); len(p.Errors) != 1 || p.Errors[0].Field != param {
+			t.Errorf("?%s errors = %+v; want one for %s", query, p.Errors, param)
+		}
+	}
+
+	// A cursor only makes sense in the order it was made for.
+	resp, _ = get(usersPath + "?sort=-name&limit=2")
+	next := strings.Replace(links(resp)["next"], "sort=-name", "sort=name", 1)
+	if resp, _ = do(t, "GET", srv.URL+next, ""); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("cursor with another sort = %d; want 400", resp.StatusCode)
+	}
+}
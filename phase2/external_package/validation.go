@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/mail"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	maxBodyBytes   = 1 << 20
+	maxNameLength  = 100
+	maxEmailLength = 254 // the longest address SMTP allows
+)
+
+// decodeJSON reads a single JSON object from the request body into v. On
+// failure it writes the problem response and returns false.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		if mt, _, _ := mime.ParseMediaType(ct); mt != "application/json" {
+			writeProblem(w, r, http.StatusUnsupportedMediaType, "request body must be application/json")
+			return false
+		}
+	}
+
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(v)
+	if err == nil && dec.Decode(&struct{}{}) != io.EOF {
+		err = errors.New("body must contain a single JSON object")
+	}
+	if err == nil {
+		return true
+	}
+
+	var (
+		maxBytes  *http.MaxBytesError
+		syntax    *json.SyntaxError
+		typeError *json.UnmarshalTypeError
+	)
+	switch {
+	case errors.As(err, &maxBytes):
+		writeProblem(w, r, http.StatusRequestEntityTooLarge, fmt.Sprintf("request body is larger than %d bytes", maxBytes.Limit))
+	case errors.As(err, &syntax):
+		writeProblem(w, r, http.StatusBadRequest, fmt.Sprintf("malformed JSON at offset %d", syntax.Offset))
+	case errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, io.EOF):
+		writeProblem(w, r, http.StatusBadRequest, "request body is empty or incomplete")
+	case errors.As(err, &typeError):
+		writeProblem(w, r, http.StatusBadRequest, "invalid field type",
+			fieldError{Field: typeError.Field, Message: "must be a " + typeError.Type.String()})
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// encoding/json has no typed error for this one.
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		writeProblem(w, r, http.StatusBadRequest, "unknown field",
+			fieldError{Field: field, Message: "is not a known field"})
+	default:
+		writeProblem(w, r, http.StatusBadRequest, err.Error())
+	}
+	return false
+}
+
+// normalize trims surrounding whitespace, which is never meaningful in a
+// name or an address.
+func (u *User) normalize() {
+	u.Name = strings.TrimSpace(u.Name)
+	u.Email = strings.TrimSpace(u.Email)
+}
+
+// validate reports every invalid field at once.
+func (u User) validate() []fieldError {
+	var errs []fieldError
+	switch {
+	case u.Name == "":
+		errs = append(errs, fieldError{Field: "name", Message: "is required"})
+	case utf8.RuneCountInString(u.Name) > maxNameLength:
+		errs = append(errs, fieldError{Field: "name", Message: fmt.Sprintf("must be at most %d characters", maxNameLength)})
+	}
+	switch {
+	case u.Email == "":
+		errs = append(errs, fieldError{Field: "email", Message: "is required"})
+	case len(u.Email) > maxEmailLength:
+		errs = append(errs, fieldError{Field: "email", Message: fmt.Sprintf("must be at most %d characters", maxEmailLength)})
+	case !validEmail(u.Email):
+		errs = append(errs, fieldError{Field: "email", Message: "must be an email address like name@example.com"})
+	}
+	return errs
+}
+
+// validEmail accepts a bare address with a dotted domain. mail.ParseAddress
+// alone also takes "Name <addr>" and dotless hosts like "root@localhost".
+func validEmail(email string) bool {
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return false
+	}
+	_, domain, _ := strings.Cut(email, "@")
+	return strings.Contains(domain, ".") && !strings.HasPrefix(domain, ".") && !strings.HasSuffix(domain, ".")
+}
+
+// validateUser writes a problem listing the invalid fields, if any, and
+// reports whether u is valid.
+func validateUser(w http.ResponseWriter, r *http.Request, u User) bool {
+	if errs := u.validate(); len(errs) > 0 {
+		writeProblem(w, r, http.StatusUnprocessableEntity, "the user has invalid fields", errs...)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestUserValidate(t *testing.T) {
+	tests := []struct {
+		user User
+		want string // fields with errors
+	}{
+		{User{Name: "Alice", Email: "alice@example.com"}, ""},
+		{User{Name: "Zoë", Email: "zoe+test@mail.example.co.uk"}, ""},
+		{User{}, "name,email"},
+		{User{Name: strings.Repeat("a", maxNameLength+1), Email: "a@example.com"}, "name"},
+		{User{Name: strings.Repeat("é", maxNameLength), Email: "a@example.com"}, ""},
+		{User{Name: "A", Email: "Alice <alice@example.com>"}, "email"},
+		{User{Name: "A", Email: "alice@localhost"}, "email"},
+		{User{Name: "A", Email: "alice.example.com"}, "email"},
+		{User{Name: "A", Email: strings.Repeat("a", 250) + "@example.com"}, "email"},
+	}
+	for _, tt := range tests {
+		var fields []string
+		for _, e := range tt.user.validate() {
+			fields = append(fields, e.Field)
+		}
+		if got := strings.Join(fields, ","); got != tt.want {
+			t.Errorf("validate(%q, %q) fields = %q; want %q", tt.user.Name, tt.user.Email, got, tt.want)
+		}
+	}
+}
+
+func decodeProblem(t *testing.T, resp *http.Response, body string) problem {
+	t.Helper()
+	if ct := resp.Header.Get("Content-Type"); ct != problemContentType {
+		t.Errorf("Content-Type = %q; want %q", ct, problemContentType)
+	}
+	var p problem
+	if err := json.Unmarshal([]byte(body), &p); err != nil {
+		t.Fatalf("decoding problem %q: %v", body, err)
+	}
+	return p
+}
+
+func TestProblemResponses(t *testing.T) {
+	srv := newTestServer(t)
+	users := srv.URL + usersPath
+	do(t, "POST", users, `{"name":"Alice","email":"alice@example.com"}`)
+
+	tests := []struct {
+		method, path, body string
+		status             int
+		field              string // expected in errors, if any
+	}{
+		{"POST", "", `{"name":"","email":"nope"}`, http.StatusUnprocessableEntity, "email"},
+		{"POST", "", `{"name":"Bob","email":"bob@example.com","admin":true}`, http.StatusBadRequest, "admin"},
+		{"POST", "", `{"name":42,"email":"bob@example.com"}`, http.StatusBadRequest, "name"},
+		{"POST", "", `{"name":"Bob"`, http.StatusBadRequest, ""},
+		{"POST", "", `{"name":"Bob","email":"bob@example.com"} {}`, http.StatusBadRequest, ""},
+		{"POST", "", ``, http.StatusBadRequest, ""},
+		{"POST", "", `{"name":"` + strings.Repeat("x", maxBodyBytes) + `"}`, http.StatusRequestEntityTooLarge, ""},
+		{"POST", "", `{"name":"Alice","email":"alice@example.com"}`, http.StatusConflict, "email"},
+		{"PATCH", "/1", `{"email":"   "}`, http.StatusUnprocessableEntity, "email"},
+		{"GET", "/9", "", http.StatusNotFound, ""},
+		{"GET", "/nope", "", http.StatusNotFound, ""},
+		{"DELETE", "", "", http.StatusMethodNotAllowed, ""},
+	}
+	for _, tt := range tests {
+		resp, body := do(t, tt.method, users+tt.path, tt.body)
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s %s %.40s = %d; want %d", tt.method, tt.path, tt.body, resp.StatusCode, tt.status)
+			continue
+		}
+		p := decodeProblem(t, resp, body)
+		if p.Status != tt.status || p.Title != http.StatusText(tt.status) || p.Type != "about:blank" {
+			t.Errorf("%s %s problem = %+v; want status %d", tt.method, tt.path, p, tt.status)
+		}
+		if tt.field != "" && (len(p.Errors) == 0 || p.Errors[len(p.Errors)-1].Field != tt.field) {
+			t.Errorf("%s %s %.40s errors = %+v; want one for %s", tt.method, tt.path, tt.body, p.Errors, tt.field)
+		}
+	}
+
+	req, _ := http.NewRequest("POST", users, strings.NewReader(`name=Bob`))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-API-Key", testAdminKey)
+	if resp, _ := send(t, req); resp.StatusCode != http.StatusUnsupportedMediaType {
+		t.Errorf("form body status = %d; want 415", resp.StatusCode)
+	}
+}
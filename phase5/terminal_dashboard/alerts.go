@@ -0,0 +1,241 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const defaultHysteresis float64 = 5
+
+// alertRule fires when a metric stays above (or below) a threshold for a
+// duration, and clears once the metric moves back past the threshold by the
+// hysteresis band.
+type alertRule struct {
+	Metric     string
+	Above      bool
+	Threshold  float64
+	For        time.Duration
+	Hysteresis float64
+}
+
+// parseAlertRule parses specs like "CPU>90:30s" or "Disk>85".
+func parseAlertRule(spec string) (alertRule, error) {
+	rule := alertRule{Hysteresis: defaultHysteresis}
+
+	cond, dur, hasDur := strings.Cut(spec, ":")
+	if hasDur {
+		d, err := time.ParseDuration(dur)
+		if err != nil || d < 0 {
+			return rule, fmt.Errorf("alert %q: invalid duration %q", spec, dur)
+		}
+		rule.For = d
+	}
+
+	op := strings.IndexAny(cond, "<>")
+	if op <= 0 {
+		return rule, fmt.Errorf("alert %q: expected METRIC>VALUE or METRIC<VALUE", spec)
+	}
+	rule.Metric = strings.TrimSpace(cond[:op])
+	rule.Above = cond[op] == '>'
+
+	v, err := strconv.ParseFloat(strings.TrimSpace(cond[op+1:]), 64)
+	if err != nil {
+		return rule, fmt.Errorf("alert %q: invalid threshold: %v", spec, err)
+	}
+	rule.Threshold = v
+
+	return rule, nil
+}
+
+func defaultAlertRules(hysteresis float64) []alertRule {
+	return []alertRule{
+		{Metric: "CPU", Above: true, Threshold: 90, For: 30 * time.Second, Hysteresis: hysteresis},
+		{Metric: "Disk", Above: true, Threshold: 85, Hysteresis: hysteresis},
+	}
+}
+
+func (r alertRule) String() string {
+	op := ">"
+	if !r.Above {
+		op = "<"
+	}
+	s := fmt.Sprintf("%s%s%g", r.Metric, op, r.Threshold)
+	if r.For > 0 {
+		s += ":" + r.For.String()
+	}
+	return s
+}
+
+// alertRules implements flag.Value so -alert can be given several times.
+type alertRules []alertRule
+
+func (a *alertRules) String() string {
+	specs := make([]string, len(*a))
+	for i, r := range *a {
+		specs[i] = r.String()
+	}
+	return strings.Join(specs, ",")
+}
+
+func (a *alertRules) Set(spec string) error {
+	r, err := parseAlertRule(spec)
+	if err != nil {
+		return err
+	}
+	*a = append(*a, r)
+	return nil
+}
+
+type alertState int
+
+const (
+	alertOK alertState = iota
+	alertPending
+	alertFiring
+)
+
+type alert struct {
+	rule  alertRule
+	state alertState
+	since time.Time
+}
+
+type alertTransition struct {
+	rule  alertRule
+	fired bool
+	value float64
+	at    time.Time
+}
+
+func (a *alert) breaching(v float64) bool {
+	if a.rule.Above {
+		return v > a.rule.Threshold
+	}
+	return v < a.rule.Threshold
+}
+
+func (a *alert) recovered(v float64) bool {
+	if a.rule.Above {
+		return v < a.rule.Threshold-a.rule.Hysteresis
+	}
+	return v > a.rule.Threshold+a.rule.Hysteresis
+}
+
+// evaluate advances the alert with a new sample and reports a transition
+// when the alert fires or clears.
+func (a *alert) evaluate(v float64, now time.Time) *alertTransition {
+	switch a.state {
+	case alertFiring:
+		if a.recovered(v) {
+			a.state = alertOK
+			a.since = time.Time{}
+			return &alertTransition{rule: a.rule, fired: false, value: v, at: now}
+		}
+	default:
+		if !a.breaching(v) {
+			a.state = alertOK
+			a.since = time.Time{}
+			return nil
+		}
+		if a.state == alertOK {
+			a.state = alertPending
+			a.since = now
+		}
+		if now.Sub(a.since) >= a.rule.For {
+			a.state = alertFiring
+			return &alertTransition{rule: a.rule, fired: true, value: v, at: now}
+		}
+	}
+
+	return nil
+}
+
+func (t alertTransition) String() string {
+	if t.fired {
+		return fmt.Sprintf("ALERT %s (%s at %.1f)", t.rule.Metric, t.rule, t.value)
+	}
+	return fmt.Sprintf("CLEAR %s (%s at %.1f)", t.rule.Metric, t.rule, t.value)
+}
+
+// alertHooks run when an alert fires or clears.
+type alertHooks struct {
+	exec string
+	file string
+}
+
+type hookErrMsg struct{ err error }
+
+func (h alertHooks) run(t alertTransition) tea.Cmd {
+	if h.exec == "" && h.file == "" {
+		return nil
+	}
+
+	return func() tea.Msg {
+		state := "cleared"
+		if t.fired {
+			state = "firing"
+		}
+
+		if h.file != "" {
+			f, err := os.OpenFile(h.file, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+			if err != nil {
+				return hookErrMsg{err}
+			}
+			_, err = fmt.Fprintf(f, "%s %s %s value=%.2f\n", t.at.Format(time.RFC3339), state, t.rule, t.value)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				return hookErrMsg{err}
+			}
+		}
+
+		if h.exec != "" {
+			cmd := exec.Command("sh", "-c", h.exec)
+			cmd.Env = append(os.Environ(),
+				"ALERT_METRIC="+t.rule.Metric,
+				"ALERT_RULE="+t.rule.String(),
+				"ALERT_STATE="+state,
+				fmt.Sprintf("ALERT_VALUE=%.2f", t.value),
+			)
+			if out, err := cmd.CombinedOutput(); err != nil {
+				return hookErrMsg{fmt.Errorf("alert hook: %v: %s", err, strings.TrimSpace(string(out)))}
+			}
+		}
+
+		return nil
+	}
+}
+
+// eventLog keeps the most recent alert and collector events for display.
+type eventLog struct {
+	entries []logEntry
+	max     int
+}
+
+type logLevel int
+
+const (
+	levelInfo logLevel = iota
+	levelWarn
+	levelAlert
+)
+
+type logEntry struct {
+	at    time.Time
+	level logLevel
+	text  string
+}
+
+func (l *eventLog) add(at time.Time, level logLevel, text string) {
+	l.entries = append(l.entries, logEntry{at: at, level: level, text: text})
+	if len(l.entries) > l.max {
+		l.entries = l.entries[len(l.entries)-l.max:]
+	}
+}
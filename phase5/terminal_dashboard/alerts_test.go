@@ -0,0 +1,83 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseAlertRule(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    alertRule
+		wantErr bool
+	}{
+		{"CPU>90:30s", alertRule{Metric: "CPU", Above: true, Threshold: 90, For: 30 * time.Second, Hysteresis: defaultHysteresis}, false},
+		{"Disk>85", alertRule{Metric: "Disk", Above: true, Threshold: 85, Hysteresis: defaultHysteresis}, false},
+		{"Memory<10:1m", alertRule{Metric: "Memory", Threshold: 10, For: time.Minute, Hysteresis: defaultHysteresis}, false},
+		{">90", alertRule{}, true},
+		{"CPU=90", alertRule{}, true},
+		{"CPU>high", alertRule{}, true},
+		{"CPU>90:soon", alertRule{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := parseAlertRule(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseAlertRule(%q) error = %v; wantErr %v", tt.spec, err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("parseAlertRule(%q) = %+v; want %+v", tt.spec, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAlertEvaluate(t *testing.T) {
+	a := &alert{rule: alertRule{Metric: "CPU", Above: true, Threshold: 90, For: 30 * time.Second, Hysteresis: 5}}
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	steps := []struct {
+		offset time.Duration
+		value  float64
+		state  alertState
+		fired  *bool
+	}{
+		{0, 95, alertPending, nil},
+		{10 * time.Second, 80, alertOK, nil},
+		{20 * time.Second, 95, alertPending, nil},
+		{49 * time.Second, 96, alertPending, nil},
+		{50 * time.Second, 96, alertFiring, ptr(true)},
+		{60 * time.Second, 87, alertFiring, nil}, // inside the hysteresis band
+		{70 * time.Second, 84, alertOK, ptr(false)},
+	}
+
+	for _, s := range steps {
+		tr := a.evaluate(s.value, start.Add(s.offset))
+		if a.state != s.state {
+			t.Errorf("at %v value %.0f: state = %v; want %v", s.offset, s.value, a.state, s.state)
+		}
+		switch {
+		case s.fired == nil && tr != nil:
+			t.Errorf("at %v: unexpected transition %v", s.offset, tr)
+		case s.fired != nil && tr == nil:
+			t.Errorf("at %v: expected transition", s.offset)
+		case s.fired != nil && tr.fired != *s.fired:
+			t.Errorf("at %v: fired = %v; want %v", s.offset, tr.fired, *s.fired)
+		}
+	}
+}
+
+func TestEventLogBounded(t *testing.T) {
+	l := &eventLog{max: 3}
+	for i := 0; i < 10; i++ {
+		l.add(time.Now(), levelInfo, "event")
+	}
+	if len(l.entries) != 3 {
+		t.Errorf("len(entries) = %d; want 3", len(l.entries))
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,432 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// v1 reports "no limit" as the largest page-aligned int64 rather than a
+// sentinel; anything this large is treated as unlimited.
+const cgroupV1Unlimited = 1 << 62
+
+// cgroupStat is the cgroup this process runs in. Inside a container its
+// limits replace the host totals that gopsutil reports for CPU and Memory.
+type cgroupStat struct {
+	Version     int
+	Path        string
+	MemoryUsage uint64  // working set: usage minus reclaimable page cache
+	MemoryLimit uint64  // 0 when unlimited
+	CPULimit    float64 // in cores, 0 when unlimited
+	CPUUsage    time.Duration
+	CPUPercent  float64 // of CPULimit, valid once cpuSampled
+	cpuSampled  bool
+
+	// Slices breaks usage down by the top-level cgroups of the hierarchy,
+	// filled in only when no limit applies (usually: running on the host).
+	Slices []sliceUsage
+}
+
+func (cg *cgroupStat) limited() bool {
+	return cg.MemoryLimit > 0 || cg.CPULimit > 0
+}
+
+type sliceUsage struct {
+	Name        string
+	MemoryUsage uint64
+	CPUUsage    time.Duration
+	CPUPercent  float64 // of all host cores
+}
+
+// cgroupDirs are the directories holding this process's controller files.
+// On v2 they're all the same directory.
+type cgroupDirs struct {
+	version int
+	path    string
+	cpu     string
+	cpuacct string
+	memory  string
+
+	// Hierarchy roots to list slices under.
+	cpuRoot    string
+	memoryRoot string
+}
+
+// detectCgroup works out the cgroup version mounted at cgroupRoot and where
+// this process's cgroup lives in it, according to procRoot/self/cgroup.
+func detectCgroup(procRoot, cgroupRoot string) (cgroupDirs, error) {
+	memberships, err := readCgroupMemberships(filepath.Join(procRoot, "self", "cgroup"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return cgroupDirs{}, errUnavailable
+	}
+	if err != nil {
+		return cgroupDirs{}, err
+	}
+
+	if exists(filepath.Join(cgroupRoot, "cgroup.controllers")) {
+		path := memberships[""]
+		dir := cgroupDir(cgroupRoot, path)
+		return cgroupDirs{
+			version: 2, path: path,
+			cpu: dir, cpuacct: dir, memory: dir,
+			cpuRoot: cgroupRoot, memoryRoot: cgroupRoot,
+		}, nil
+	}
+
+	if !exists(filepath.Join(cgroupRoot, "memory")) && !exists(filepath.Join(cgroupRoot, "cpuacct")) {
+		return cgroupDirs{}, errUnavailable
+	}
+	d := cgroupDirs{
+		version:    1,
+		path:       memberships["memory"],
+		cpuRoot:    filepath.Join(cgroupRoot, "cpuacct"),
+		memoryRoot: filepath.Join(cgroupRoot, "memory"),
+	}
+	d.cpu = cgroupDir(filepath.Join(cgroupRoot, "cpu"), memberships["cpu"])
+	d.cpuacct = cgroupDir(d.cpuRoot, memberships["cpuacct"])
+	d.memory = cgroupDir(d.memoryRoot, memberships["memory"])
+	return d, nil
+}
+
+// readCgroupMemberships maps each v1 controller to its cgroup path. The v2
+// unified hierarchy is under the empty controller name.
+func readCgroupMemberships(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	paths := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// hierarchy-ID:controller-list:cgroup-path
+		fields := strings.SplitN(scanner.Text(), ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		if fields[1] == "" {
+			paths[""] = fields[2]
+			continue
+		}
+		for _, controller := range strings.Split(fields[1], ",") {
+			paths[controller] = fields[2]
+		}
+	}
+	return paths, scanner.Err()
+}
+
+// cgroupDir joins a cgroup path onto its mount. A container without its
+// own cgroup namespace sees the host's path in /proc/self/cgroup but has
+// its own cgroup mounted at the root, so fall back to the mount.
+func cgroupDir(mount, path string) string {
+	dir := filepath.Join(mount, path)
+	if exists(dir) {
+		return dir
+	}
+	return mount
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func readCgroupUint(path string) (uint64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+}
+
+// readCgroupKeyed reads one key from a "key value" file like memory.stat.
+func readCgroupKeyed(path, key string) (uint64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		k, v, ok := strings.Cut(line, " ")
+		if ok && k == key {
+			return strconv.ParseUint(strings.TrimSpace(v), 10, 64)
+		}
+	}
+	return 0, fmt.Errorf("%s: no %s", filepath.Base(path), key)
+}
+
+func (d cgroupDirs) memoryUsage(dir string) (uint64, error) {
+	usageFile, inactiveFile, inactiveKey := "memory.current", "memory.stat", "inactive_file"
+	if d.version == 1 {
+		usageFile, inactiveKey = "memory.usage_in_bytes", "total_inactive_file"
+	}
+	usage, err := readCgroupUint(filepath.Join(dir, usageFile))
+	if err != nil {
+		return 0, err
+	}
+	if inactive, err := readCgroupKeyed(filepath.Join(dir, inactiveFile), inactiveKey); err == nil && inactive < usage {
+		usage -= inactive
+	}
+	return usage, nil
+}
+
+func (d cgroupDirs) memoryLimit() (uint64, error) {
+	if d.version == 1 {
+		limit, err := readCgroupUint(filepath.Join(d.memory, "memory.limit_in_bytes"))
+		if err != nil || limit >= cgroupV1Unlimited {
+			return 0, err
+		}
+		return limit, nil
+	}
+	data, err := os.ReadFile(filepath.Join(d.memory, "memory.max"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil // the root cgroup has no limit files
+	}
+	if err != nil {
+		return 0, err
+	}
+	v := strings.TrimSpace(string(data))
+	if v == "max" {
+		return 0, nil
+	}
+	return strconv.ParseUint(v, 10, 64)
+}
+
+func (d cgroupDirs) cpuUsage(dir string) (time.Duration, error) {
+	if d.version == 1 {
+		ns, err := readCgroupUint(filepath.Join(dir, "cpuacct.usage"))
+		return time.Duration(ns), err
+	}
+	us, err := readCgroupKeyed(filepath.Join(dir, "cpu.stat"), "usage_usec")
+	return time.Duration(us) * time.Microsecond, err
+}
+
+func (d cgroupDirs) cpuLimit() (float64, error) {
+	var quota, period string
+	if d.version == 1 {
+		q, err := os.ReadFile(filepath.Join(d.cpu, "cpu.cfs_quota_us"))
+		if errors.Is(err, fs.ErrNotExist) {
+			return 0, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		p, err := os.ReadFile(filepath.Join(d.cpu, "cpu.cfs_period_us"))
+		if err != nil {
+			return 0, err
+		}
+		quota, period = strings.TrimSpace(string(q)), strings.TrimSpace(string(p))
+	} else {
+		data, err := os.ReadFile(filepath.Join(d.cpu, "cpu.max"))
+		if errors.Is(err, fs.ErrNotExist) {
+			return 0, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		var ok bool
+		quota, period, ok = strings.Cut(strings.TrimSpace(string(data)), " ")
+		if !ok {
+			return 0, fmt.Errorf("cpu.max: malformed %q", data)
+		}
+	}
+	if quota == "max" || quota == "-1" {
+		return 0, nil
+	}
+	q, err := strconv.ParseFloat(quota, 64)
+	if err != nil {
+		return 0, fmt.Errorf("cpu quota: %v", err)
+	}
+	p, err := strconv.ParseFloat(period, 64)
+	if err != nil || p <= 0 {
+		return 0, fmt.Errorf("cpu period: %q", period)
+	}
+	return q / p, nil
+}
+
+// slices lists the top-level cgroups of the hierarchy with their usage.
+func (d cgroupDirs) slices() []sliceUsage {
+	byName := map[string]*sliceUsage{}
+	get := func(name string) *sliceUsage {
+		if byName[name] == nil {
+			byName[name] = &sliceUsage{Name: name}
+		}
+		return byName[name]
+	}
+	for _, name := range childDirs(d.memoryRoot) {
+		if usage, err := d.memoryUsage(filepath.Join(d.memoryRoot, name)); err == nil {
+			get(name).MemoryUsage = usage
+		}
+	}
+	for _, name := range childDirs(d.cpuRoot) {
+		if usage, err := d.cpuUsage(filepath.Join(d.cpuRoot, name)); err == nil {
+			get(name).CPUUsage = usage
+		}
+	}
+
+	slices := make([]sliceUsage, 0, len(byName))
+	for _, name := range sortedKeys(byName) {
+		slices = append(slices, *byName[name])
+	}
+	return slices
+}
+
+func childDirs(dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, e := range entries {
+		if e.IsDir() {
+			names = append(names, e.Name())
+		}
+	}
+	return names
+}
+
+// cgroupReader is the "Cgroup" source. It keeps the previous CPU counters
+// so usage can be turned into a percentage.
+type cgroupReader struct {
+	procRoot   string
+	cgroupRoot string
+	cpus       int
+
+	mu       sync.Mutex
+	lastTime time.Time
+	lastCPU  map[string]time.Duration // by slice name, "" for our own cgroup
+}
+
+func newCgroupReader(procRoot, cgroupRoot string) *cgroupReader {
+	return &cgroupReader{
+		procRoot:   procRoot,
+		cgroupRoot: cgroupRoot,
+		cpus:       runtime.NumCPU(),
+		lastCPU:    map[string]time.Duration{},
+	}
+}
+
+func (r *cgroupReader) source() source {
+	return source{name: "Cgroup", timeout: time.Second, read: r.read}
+}
+
+func (r *cgroupReader) read(_ context.Context, t time.Time) (func(*sample), error) {
+	d, err := detectCgroup(r.procRoot, r.cgroupRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	cg := &cgroupStat{Version: d.version, Path: d.path}
+	if cg.MemoryLimit, err = d.memoryLimit(); err != nil {
+		return nil, err
+	}
+	if cg.CPULimit, err = d.cpuLimit(); err != nil {
+		return nil, err
+	}
+	// The root cgroup on v2 has no memory.current or cpu.stat usage of its
+	// own; that's fine as long as nothing is limited.
+	usage, memErr := d.memoryUsage(d.memory)
+	cpuUsage, cpuErr := d.cpuUsage(d.cpuacct)
+	if cg.limited() {
+		if memErr != nil {
+			return nil, memErr
+		}
+		if cpuErr != nil {
+			return nil, cpuErr
+		}
+	} else {
+		cg.Slices = d.slices()
+	}
+	cg.MemoryUsage, cg.CPUUsage = usage, cpuUsage
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	elapsed := t.Sub(r.lastTime)
+	prev := r.lastCPU
+	r.lastCPU = map[string]time.Duration{"": cg.CPUUsage}
+	for _, sl := range cg.Slices {
+		r.lastCPU[sl.Name] = sl.CPUUsage
+	}
+	sampled := !r.lastTime.IsZero() && elapsed > 0
+	r.lastTime = t
+	if !sampled {
+		return func(s *sample) { s.Cgroup = cg }, nil
+	}
+
+	if last, ok := prev[""]; ok && cg.CPULimit > 0 && cpuErr == nil {
+		cg.CPUPercent = cpuPercent(cg.CPUUsage-last, elapsed, cg.CPULimit)
+		cg.cpuSampled = true
+	}
+	for i := range cg.Slices {
+		sl := &cg.Slices[i]
+		if last, ok := prev[sl.Name]; ok {
+			sl.CPUPercent = cpuPercent(sl.CPUUsage-last, elapsed, float64(r.cpus))
+		}
+	}
+	sort.SliceStable(cg.Slices, func(i, j int) bool {
+		return cg.Slices[i].CPUPercent > cg.Slices[j].CPUPercent
+	})
+
+	return func(s *sample) { s.Cgroup = cg }, nil
+}
+
+func cpuPercent(used, elapsed time.Duration, cores float64) float64 {
+	if used < 0 {
+		return 0 // counter reset, e.g. the cgroup was recreated
+	}
+	return 100 * used.Seconds() / elapsed.Seconds() / cores
+}
+
+// applyCgroupLimits rescales CPU and Memory to the cgroup's quotas, so a
+// container at its memory limit shows 100% rather than its share of the
+// host.
+func applyCgroupLimits(s *sample) {
+	cg := s.Cgroup
+	if cg == nil {
+		return
+	}
+	if _, ok := s.Metrics["Memory"]; ok && cg.MemoryLimit > 0 {
+		s.Metrics["Memory"] = 100 * float64(cg.MemoryUsage) / float64(cg.MemoryLimit)
+	}
+	if _, ok := s.Metrics["CPU"]; ok && cg.cpuSampled {
+		s.Metrics["CPU"] = cg.CPUPercent
+	}
+}
+
+func formatBytes(b uint64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%d B", b)
+	}
+	div, exp := uint64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(b)/float64(div), "KMGTPE"[exp])
+}
+
+// cgroupSummary describes the limits in effect, for the title bar.
+func cgroupSummary(cg *cgroupStat) string {
+	if cg == nil || !cg.limited() {
+		return ""
+	}
+	var parts []string
+	if cg.CPULimit > 0 {
+		parts = append(parts, strconv.FormatFloat(cg.CPULimit, 'g', 3, 64)+" CPU")
+	}
+	if cg.MemoryLimit > 0 {
+		parts = append(parts, formatBytes(cg.MemoryLimit))
+	}
+	return fmt.Sprintf("cgroup v%d: %s", cg.Version, strings.Join(parts, ", "))
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeTree lays out a fixture filesystem from relative paths to contents.
+func writeTree(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, data := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCgroupV2Container(t *testing.T) {
+	root := t.TempDir()
+	proc, cgroup := filepath.Join(root, "proc"), filepath.Join(root, "cgroup")
+	// With a cgroup namespace the container sees itself at "/".
+	writeTree(t, root, map[string]string{
+		"proc/self/cgroup":          "0::/\n",
+		"cgroup/cgroup.controllers": "cpu memory\n",
+		"cgroup/memory.max":         "536870912\n",
+		"cgroup/memory.current":     "300000000\n",
+		"cgroup/memory.stat":        "anon 200000000\ninactive_file 31564800\n",
+		"cgroup/cpu.max":            "150000 100000\n",
+		"cgroup/cpu.stat":           "usage_usec 1000000\nuser_usec 800000\n",
+	})
+
+	r := newCgroupReader(proc, cgroup)
+	start := time.Unix(1700000000, 0)
+	if _, err := r.read(context.Background(), start); err != nil {
+		t.Fatal(err)
+	}
+	// 0.75s of CPU over 1s against a 1.5 core quota is 50%.
+	writeTree(t, root, map[string]string{"cgroup/cpu.stat": "usage_usec 1750000\n"})
+	fill, err := r.read(context.Background(), start.Add(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := sample{Metrics: map[string]float64{"CPU": 3, "Memory": 10}}
+	fill(&s)
+	applyCgroupLimits(&s)
+
+	cg := s.Cgroup
+	if cg.Version != 2 || cg.CPULimit != 1.5 || cg.MemoryLimit != 536870912 {
+		t.Errorf("cgroup = %+v", cg)
+	}
+	if cg.MemoryUsage != 268435200 {
+		t.Errorf("MemoryUsage = %d; want usage minus inactive_file", cg.MemoryUsage)
+	}
+	if got := s.Metrics["CPU"]; math.Abs(got-50) > 1e-9 {
+		t.Errorf("CPU = %v; want 50", got)
+	}
+	if got := s.Metrics["Memory"]; math.Abs(got-50) > 0.01 {
+		t.Errorf("Memory = %v; want 50", got)
+	}
+	if len(cg.Slices) != 0 {
+		t.Errorf("a limited cgroup should not list slices: %v", cg.Slices)
+	}
+	if got, want := cgroupSummary(cg), "cgroup v2: 1.5 CPU, 512.0 MiB"; got != want {
+		t.Errorf("cgroupSummary = %q; want %q", got, want)
+	}
+}
+
+func TestCgroupV1ContainerHostPath(t *testing.T) {
+	root := t.TempDir()
+	proc, cgroup := filepath.Join(root, "proc"), filepath.Join(root, "cgroup")
+	// Without a cgroup namespace /proc/self/cgroup shows the host's path,
+	// but only the container's own cgroup is mounted.
+	writeTree(t, root, map[string]string{
+		"proc/self/cgroup":                    "4:memory:/docker/abc\n3:cpu,cpuacct:/docker/abc\n1:name=systemd:/docker/abc\n",
+		"cgroup/memory/memory.limit_in_bytes": "1073741824\n",
+		"cgroup/memory/memory.usage_in_bytes": "268435456\n",
+		"cgroup/memory/memory.stat":           "cache 0\ntotal_inactive_file 0\n",
+		"cgroup/cpu/cpu.cfs_quota_us":         "200000\n",
+		"cgroup/cpu/cpu.cfs_period_us":        "100000\n",
+		"cgroup/cpuacct/cpuacct.usage":        "5000000000\n",
+	})
+
+	fill, err := newCgroupReader(proc, cgroup).read(context.Background(), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := sample{Metrics: map[string]float64{"CPU": 3, "Memory": 10}}
+	fill(&s)
+	applyCgroupLimits(&s)
+
+	cg := s.Cgroup
+	if cg.Version != 1 || cg.CPULimit != 2 || cg.CPUUsage != 5*time.Second {
+		t.Errorf("cgroup = %+v", cg)
+	}
+	if s.Metrics["Memory"] != 25 {
+		t.Errorf("Memory = %v; want 25", s.Metrics["Memory"])
+	}
+	// No CPU delta yet, so the host value stays.
+	if s.Metrics["CPU"] != 3 {
+		t.Errorf("CPU = %v; want host value 3 on the first read", s.Metrics["CPU"])
+	}
+}
+
+func TestCgroupV2HostSlices(t *testing.T) {
+	root := t.TempDir()
+	proc, cgroup := filepath.Join(root, "proc"), filepath.Join(root, "cgroup")
+	writeTree(t, root, map[string]string{
+		"proc/self/cgroup":                                                 "0::/user.slice/user-1000.slice/session-2.scope\n",
+		"cgroup/cgroup.controllers":                                        "cpu memory\n",
+		"cgroup/system.slice/memory.current":                               "2147483648\n",
+		"cgroup/system.slice/cpu.stat":                                     "usage_usec 0\n",
+		"cgroup/user.slice/memory.current":                                 "1073741824\n",
+		"cgroup/user.slice/cpu.stat":                                       "usage_usec 0\n",
+		"cgroup/user.slice/memory.max":                                     "max\n",
+		"cgroup/user.slice/cpu.max":                                        "max 100000\n",
+		"cgroup/user.slice/user-1000.slice/session-2.scope/memory.max":     "max\n",
+		"cgroup/user.slice/user-1000.slice/session-2.scope/cpu.max":        "max 100000\n",
+		"cgroup/user.slice/user-1000.slice/session-2.scope/memory.current": "1000\n",
+		"cgroup/user.slice/user-1000.slice/session-2.scope/cpu.stat":       "usage_usec 0\n",
+	})
+
+	r := newCgroupReader(proc, cgroup)
+	r.cpus = 4
+	start := time.Unix(1700000000, 0)
+	if _, err := r.read(context.Background(), start); err != nil {
+		t.Fatal(err)
+	}
+	writeTree(t, root, map[string]string{
+		"cgroup/system.slice/cpu.stat": "usage_usec 2000000\n",
+		"cgroup/user.slice/cpu.stat":   "usage_usec 1000000\n",
+	})
+	fill, err := r.read(context.Background(), start.Add(2*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := sample{Metrics: map[string]float64{"CPU": 7, "Memory": 40}}
+	fill(&s)
+	applyCgroupLimits(&s)
+
+	if s.Metrics["CPU"] != 7 || s.Metrics["Memory"] != 40 {
+		t.Errorf("unlimited cgroup changed host metrics: %v", s.Metrics)
+	}
+	want := []sliceUsage{
+		{Name: "system.slice", MemoryUsage: 2147483648, CPUUsage: 2 * time.Second, CPUPercent: 25},
+		{Name: "user.slice", MemoryUsage: 1073741824, CPUUsage: time.Second, CPUPercent: 12.5},
+	}
+	got := s.Cgroup.Slices
+	if len(got) != len(want) {
+		t.Fatalf("slices = %+v; want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("slice %d = %+v; want %+v", i, got[i], want[i])
+		}
+	}
+	if cgroupSummary(s.Cgroup) != "" {
+		t.Errorf("cgroupSummary = %q; want none without limits", cgroupSummary(s.Cgroup))
+	}
+
+	// A failed read keeps the slices on screen, marked stale.
+	m := initialModel(options{})
+	s.Time = start
+	m, _ = m.applySample(s)
+	m, _ = m.applySample(sample{
+		Time:    start.Add(time.Second),
+		Metrics: map[string]float64{"CPU": 7, "Memory": 40},
+		Errors:  map[string]error{"Cgroup": errors.New("permission denied")},
+	})
+	if panel := m.slicePanel(80); !strings.Contains(panel, "system.slice") || !strings.Contains(panel, "⚠ error") {
+		t.Errorf("slice panel after a failed read:\n%s", panel)
+	}
+}
+
+func TestCgroupUnavailable(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{"proc/self/cgroup": "0::/\n"})
+
+	_, err := newCgroupReader(filepath.Join(root, "proc"), filepath.Join(root, "cgroup")).read(context.Background(), time.Now())
+	if !errors.Is(err, errUnavailable) {
+		t.Errorf("err = %v; want errUnavailable", err)
+	}
+}
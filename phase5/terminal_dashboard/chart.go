@@ -0,0 +1,247 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+type scaleMode int
+
+const (
+	// scaleAbsolute pins the axis to 0-100, for percentages.
+	scaleAbsolute scaleMode = iota
+	// scaleAuto fits the axis to the data in view, for rates.
+	scaleAuto
+)
+
+type metricInfo struct {
+	unit  string
+	scale scaleMode
+	// factor converts from the unit the metric is collected in to unit;
+	// zero means they're the same.
+	factor float64
+}
+
+// displayUnits lists, by the unit a metric is collected in, the units it
+// can be shown in and how to convert to them.
+var displayUnits = map[string]map[string]float64{
+	"%":    {"%": 1},
+	"":     {"": 1},
+	"KB/s": {"KB/s": 1, "B/s": 1024, "MB/s": 1.0 / 1024, "Mbit/s": 1024 * 8 / 1e6},
+}
+
+var metricInfos = map[string]metricInfo{
+	"CPU":     {unit: "%", scale: scaleAbsolute},
+	"Memory":  {unit: "%", scale: scaleAbsolute},
+	"Disk":    {unit: "%", scale: scaleAbsolute},
+	"Network": {unit: "KB/s", scale: scaleAuto},
+	"Swap":    {unit: "%", scale: scaleAbsolute},
+	"Load":    {scale: scaleAuto},
+	// Pressure is a percentage too, but is usually in single digits, so
+	// an auto-ranged axis shows it better.
+	"PSI cpu": {unit: "%", scale: scaleAuto},
+	"PSI mem": {unit: "%", scale: scaleAuto},
+	"PSI io":  {unit: "%", scale: scaleAuto},
+}
+
+func infoFor(metric string) metricInfo {
+	if info, ok := metricInfos[metric]; ok {
+		return info
+	}
+	return metricInfo{scale: scaleAuto}
+}
+
+func (i metricInfo) convert(v float64) float64 {
+	if i.factor == 0 {
+		return v
+	}
+	return v * i.factor
+}
+
+// scaleRange returns the axis range for a metric given the data in view.
+func scaleRange(metric string, data []float64) (lo, hi float64) {
+	return infoFor(metric).scaleRange(data)
+}
+
+// scaleRange returns the axis range, in collected units, for the data in
+// view. Auto-ranged metrics start at zero and round the top up to a number
+// that's nice in the display unit so the axis doesn't jitter on every tick.
+func (i metricInfo) scaleRange(data []float64) (lo, hi float64) {
+	if i.scale == scaleAbsolute {
+		return 0, 100
+	}
+	hi = 0
+	for _, v := range data {
+		if v > hi {
+			hi = v
+		}
+	}
+	return 0, niceCeil(i.convert(hi)) / i.convert(1)
+}
+
+func niceCeil(v float64) float64 {
+	if v <= 1 {
+		return 1
+	}
+	exp := math.Pow(10, math.Floor(math.Log10(v)))
+	for _, m := range []float64{1, 2, 2.5, 5, 10} {
+		if v <= m*exp {
+			return m * exp
+		}
+	}
+	return 10 * exp
+}
+
+func formatMetric(metric string, v float64) string {
+	return infoFor(metric).format(v)
+}
+
+func (i metricInfo) format(v float64) string {
+	v = i.convert(v)
+	switch i.unit {
+	case "%":
+		return fmt.Sprintf("%5.1f%%", v)
+	case "KB/s":
+		switch {
+		case v >= 1024*1024:
+			return fmt.Sprintf("%5.1f GB/s", v/1024/1024)
+		case v >= 1024:
+			return fmt.Sprintf("%5.1f MB/s", v/1024)
+		}
+		return fmt.Sprintf("%5.1f KB/s", v)
+	case "":
+		return fmt.Sprintf("%5.2f", v)
+	}
+	return fmt.Sprintf("%5.1f %s", v, i.unit)
+}
+
+// brailleDots maps a dot's (x, y) position within a 2x4 braille cell to
+// its bit in the Unicode braille block.
+var brailleDots = [2][4]rune{
+	{0x01, 0x02, 0x04, 0x40},
+	{0x08, 0x10, 0x20, 0x80},
+}
+
+// brailleCanvas is a grid of braille cells, each holding 2x4 dots, giving
+// line charts four times the vertical resolution of block sparklines.
+type brailleCanvas struct {
+	width, height int
+	cells         []rune
+}
+
+func newBrailleCanvas(width, height int) *brailleCanvas {
+	return &brailleCanvas{width: width, height: height, cells: make([]rune, width*height)}
+}
+
+// set lights the dot at (x, y), with y = 0 at the bottom.
+func (c *brailleCanvas) set(x, y int) {
+	if x < 0 || y < 0 || x >= c.width*2 || y >= c.height*4 {
+		return
+	}
+	row := c.height - 1 - y/4
+	c.cells[row*c.width+x/2] |= brailleDots[x%2][3-y%4]
+}
+
+func (c *brailleCanvas) line(x0, y0, x1, y1 int) {
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+	err := dx + dy
+	for {
+		c.set(x0, y0)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		if e2 := 2 * err; e2 >= dy {
+			err += dy
+			x0 += sx
+		} else {
+			err += dx
+			y0 += sy
+		}
+	}
+}
+
+func (c *brailleCanvas) rows() []string {
+	out := make([]string, c.height)
+	for r := range out {
+		var b strings.Builder
+		for _, cell := range c.cells[r*c.width : (r+1)*c.width] {
+			if cell == 0 {
+				b.WriteRune(' ')
+			} else {
+				b.WriteRune(0x2800 + cell)
+			}
+		}
+		out[r] = b.String()
+	}
+	return out
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// lineChart plots data (two points per column) as a braille line chart with
+// a labelled y axis and a time axis spanning window. NaN points are gaps.
+func lineChart(info metricInfo, data []float64, width, height int, window time.Duration) string {
+	const axisWidth = 11
+	plotWidth := max(1, width-axisWidth)
+	lo, hi := info.scaleRange(data)
+
+	canvas := newBrailleCanvas(plotWidth, height)
+	dotsY := height*4 - 1
+	prevX, prevY := -1, 0
+	for i, v := range data {
+		if math.IsNaN(v) {
+			prevX = -1
+			continue
+		}
+		x := i * plotWidth * 2 / max(1, len(data))
+		y := int(math.Round((math.Min(math.Max(v, lo), hi) - lo) / (hi - lo) * float64(dotsY)))
+		if prevX >= 0 {
+			canvas.line(prevX, prevY, x, y)
+		} else {
+			canvas.set(x, y)
+		}
+		prevX, prevY = x, y
+	}
+
+	var b strings.Builder
+	for r, row := range canvas.rows() {
+		label := ""
+		switch r {
+		case 0:
+			label = info.format(hi)
+		case height / 2:
+			label = info.format(lo + (hi-lo)*float64(height-1-r)/float64(height-1))
+		case height - 1:
+			label = info.format(lo)
+		}
+		fmt.Fprintf(&b, "%*s ┤%s\n", axisWidth-2, strings.TrimSpace(label), row)
+	}
+
+	fmt.Fprintf(&b, "%*s └%s\n", axisWidth-2, "", strings.Repeat("─", plotWidth))
+	left := "-" + windowLabel(window)
+	mid := "-" + windowLabel(window/2)
+	right := "now"
+	gap := plotWidth - len(left) - len(mid) - len(right)
+	if gap >= 2 {
+		fmt.Fprintf(&b, "%*s  %s%*s%s%*s%s", axisWidth-2, "", left,
+			gap/2, "", mid, gap-gap/2, "", right)
+	} else {
+		fmt.Fprintf(&b, "%*s  %s%*s", axisWidth-2, "", left, plotWidth-len(left), right)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSparklineAbsoluteScale(t *testing.T) {
+	var m model
+	lo, hi := scaleRange("CPU", []float64{1, 2, 3})
+
+	// Values that previously all rendered as the lowest glyph because the
+	// range was seeded at 33-67.
+	got := m.createSparkline([]float64{0, 15, 30, 100, math.NaN()}, 5, lo, hi)
+	if got != "▁▂▃█ " {
+		t.Errorf("createSparkline = %q; want %q", got, "▁▂▃█ ")
+	}
+}
+
+func TestScaleRange(t *testing.T) {
+	tests := []struct {
+		metric string
+		data   []float64
+		lo, hi float64
+	}{
+		{"CPU", []float64{3, 4}, 0, 100},
+		{"Network", []float64{3, 42}, 0, 50},
+		{"Network", []float64{0.2}, 0, 1},
+		{"Network", []float64{730, math.NaN()}, 0, 1000},
+	}
+
+	for _, tt := range tests {
+		lo, hi := scaleRange(tt.metric, tt.data)
+		if lo != tt.lo || hi != tt.hi {
+			t.Errorf("scaleRange(%s, %v) = %v, %v; want %v, %v", tt.metric, tt.data, lo, hi, tt.lo, tt.hi)
+		}
+	}
+}
+
+func TestBrailleCanvas(t *testing.T) {
+	c := newBrailleCanvas(2, 1)
+	c.set(0, 0) // bottom-left dot of the first cell
+	c.set(3, 3) // top-right dot of the second cell
+
+	rows := c.rows()
+	if rows[0] != "⡀⠈" {
+		t.Errorf("rows = %q; want %q", rows[0], "⡀⠈")
+	}
+}
+
+func TestLineChartAxes(t *testing.T) {
+	data := []float64{0, 25, 50, 75, 100, 75, 50, 25}
+	out := lineChart(infoFor("CPU"), data, 40, 4, 30*time.Second)
+	lines := strings.Split(out, "\n")
+
+	if len(lines) != 6 {
+		t.Fatalf("chart has %d lines; want 4 plot rows plus 2 axis lines", len(lines))
+	}
+	if !strings.Contains(lines[0], "100.0%") || !strings.Contains(lines[3], "0.0%") {
+		t.Errorf("y axis labels missing:\n%s", out)
+	}
+	if !strings.Contains(lines[5], "-30s") || !strings.HasSuffix(lines[5], "now") {
+		t.Errorf("x axis labels missing:\n%s", out)
+	}
+}
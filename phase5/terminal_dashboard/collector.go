@@ -0,0 +1,434 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/shirou/gopsutil/v4/cpu"
+	"github.com/shirou/gopsutil/v4/disk"
+	"github.com/shirou/gopsutil/v4/load"
+	"github.com/shirou/gopsutil/v4/mem"
+	"github.com/shirou/gopsutil/v4/net"
+)
+
+var (
+	errNoInterfaces = errors.New("no network interfaces")
+	errInFlight     = errors.New("previous read still running")
+)
+
+// timeoutError reports a collector that didn't answer within its timeout.
+type timeoutError struct {
+	after time.Duration
+}
+
+func (e timeoutError) Error() string {
+	return fmt.Sprintf("timed out after %v", e.after)
+}
+
+type lastValues struct {
+	netBytesSent uint64
+	netBytesRecv uint64
+	netTime      time.Time
+}
+
+// sample is one tick's worth of collected metrics. It is what the live
+// collector produces, what gets recorded, and what replay feeds back.
+type sample struct {
+	Time    time.Time
+	Metrics map[string]float64
+	Errors  map[string]error
+	Sources []string // the sources read for this sample, failed or not
+
+	// Breakdown behind the headline metrics, used by the exporter.
+	Cores      []float64
+	Memory     *mem.VirtualMemoryStat
+	Swap       *mem.SwapMemoryStat
+	Load       *load.AvgStat
+	Pressure   map[string]pressure
+	Cgroup     *cgroupStat
+	Processes  []procInfo
+	Sensors    *sensorStat
+	Mounts     []mountUsage
+	Interfaces []net.IOCountersStat
+}
+
+type mountUsage struct {
+	disk.PartitionStat
+	Usage *disk.UsageStat
+}
+
+// MarshalJSON writes read errors as their messages, which is all of an
+// error that survives a recording.
+func (s sample) MarshalJSON() ([]byte, error) {
+	type plain sample
+	out := struct {
+		plain
+		Errors map[string]string `json:",omitempty"`
+	}{plain: plain(s)}
+	for name, err := range s.Errors {
+		if out.Errors == nil {
+			out.Errors = map[string]string{}
+		}
+		out.Errors[name] = err.Error()
+	}
+	return json.Marshal(out)
+}
+
+func (s *sample) UnmarshalJSON(data []byte) error {
+	type plain sample
+	var in struct {
+		plain
+		Errors map[string]string
+	}
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+	*s = sample(in.plain)
+	s.Errors = map[string]error{}
+	for name, msg := range in.Errors {
+		s.Errors[name] = errors.New(msg)
+	}
+	return nil
+}
+
+// source reads one part of a sample. read fills in the sample through the
+// returned func so a timed-out read never touches a sample it was too late
+// for.
+type source struct {
+	name    string
+	timeout time.Duration
+	read    func(ctx context.Context, t time.Time) (func(*sample), error)
+}
+
+// reading is the outcome of one source for one sample.
+type reading struct {
+	source string
+	fill   func(*sample)
+	err    error
+	took   time.Duration
+}
+
+// collectorConfig points collectors at alternate filesystem roots, so they
+// can be run against fixtures, and tunes which sources run.
+type collectorConfig struct {
+	procRoot   string
+	cgroupRoot string
+	sysRoot    string
+	diskPath   string
+	disabled   []string                 // source names not to read
+	timeouts   map[string]time.Duration // per-source timeout overrides
+}
+
+type collector struct {
+	cfg     collectorConfig
+	readers []source // every source, before cfg disables or retimes any
+	sources []source
+
+	mu       sync.Mutex
+	inflight map[string]bool
+	last     lastValues
+}
+
+func newCollector(cfg collectorConfig) *collector {
+	c := &collector{
+		inflight: map[string]bool{},
+		last: lastValues{
+			netBytesSent: math.MaxUint64,
+			netBytesRecv: math.MaxUint64,
+		},
+	}
+	c.configure(cfg)
+	return c
+}
+
+// configure switches c to cfg in place, so a config reload keeps the
+// network baseline and the in-flight guard. Some readers keep counters
+// between samples too; they are only built again when the roots they
+// read from change.
+func (c *collector) configure(cfg collectorConfig) {
+	if c.readers == nil || cfg.procRoot != c.cfg.procRoot || cfg.cgroupRoot != c.cfg.cgroupRoot ||
+		cfg.sysRoot != c.cfg.sysRoot || cfg.diskPath != c.cfg.diskPath {
+		c.readers = c.newReaders(cfg)
+	}
+	c.cfg = cfg
+	c.sources = nil
+	for _, src := range c.readers {
+		if slices.Contains(cfg.disabled, src.name) {
+			continue
+		}
+		if d, ok := cfg.timeouts[src.name]; ok {
+			src.timeout = d
+		}
+		c.sources = append(c.sources, src)
+	}
+}
+
+// knownSources names every source newReaders builds, for validating
+// config without building a collector.
+var knownSources = []string{"CPU", "Memory", "Swap", "Load", "Pressure", "Cgroup", "Disk", "Mounts", "Network", sensorsPanel, processesPanel}
+
+func (c *collector) newReaders(cfg collectorConfig) []source {
+	if cfg.procRoot == "" {
+		cfg.procRoot = "/proc"
+	}
+	if cfg.cgroupRoot == "" {
+		cfg.cgroupRoot = "/sys/fs/cgroup"
+	}
+	if cfg.sysRoot == "" {
+		cfg.sysRoot = "/sys"
+	}
+	if cfg.diskPath == "" {
+		cfg.diskPath = "/"
+	}
+	return []source{
+		{name: "CPU", timeout: 2 * time.Second, read: readCPU},
+		{name: "Memory", timeout: 2 * time.Second, read: readMemory},
+		{name: "Swap", timeout: 2 * time.Second, read: readSwap},
+		{name: "Load", timeout: time.Second, read: readLoad},
+		pressureSource(cfg.procRoot),
+		newCgroupReader(cfg.procRoot, cfg.cgroupRoot).source(),
+		{name: "Disk", timeout: 3 * time.Second, read: diskReader(cfg.diskPath)},
+		{name: "Mounts", timeout: 5 * time.Second, read: readMounts},
+		{name: "Network", timeout: 2 * time.Second, read: c.readNetwork},
+		sensorsSource(cfg.sysRoot),
+		newProcReader().source(),
+	}
+}
+
+// sourceNames lists the sources that get read, in order.
+func (c *collector) sourceNames() []string {
+	names := make([]string, len(c.sources))
+	for i, src := range c.sources {
+		names[i] = src.name
+	}
+	return names
+}
+
+// read runs one source with its timeout. The underlying call may keep
+// blocking after the timeout (a hung NFS mount ignores cancellation), so
+// the source is marked in flight and skipped until that call returns.
+func (c *collector) read(src source, t time.Time) reading {
+	c.mu.Lock()
+	if c.inflight[src.name] {
+		c.mu.Unlock()
+		return reading{source: src.name, err: errInFlight}
+	}
+	c.inflight[src.name] = true
+	c.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), src.timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan reading, 1)
+	go func() {
+		fill, err := src.read(ctx, t)
+		c.mu.Lock()
+		delete(c.inflight, src.name)
+		c.mu.Unlock()
+		done <- reading{source: src.name, fill: fill, err: err, took: time.Since(start)}
+	}()
+
+	select {
+	case r := <-done:
+		return r
+	case <-ctx.Done():
+		return reading{source: src.name, err: timeoutError{src.timeout}, took: time.Since(start)}
+	}
+}
+
+// collect reads every source concurrently and waits for all of them. It is
+// the blocking path used by agent mode; the TUI reads sources as commands.
+func (c *collector) collect(t time.Time) sample {
+	readings := make([]reading, len(c.sources))
+	var wg sync.WaitGroup
+	for i, src := range c.sources {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			readings[i] = c.read(src, t)
+		}()
+	}
+	wg.Wait()
+
+	return assemble(t, readings)
+}
+
+func assemble(t time.Time, readings []reading) sample {
+	s := sample{
+		Time:    t,
+		Metrics: map[string]float64{},
+		Errors:  map[string]error{},
+	}
+	for _, r := range readings {
+		if errors.Is(r.err, errUnavailable) {
+			continue
+		}
+		s.Sources = append(s.Sources, r.source)
+		if r.err != nil {
+			s.Errors[r.source] = r.err
+			continue
+		}
+		r.fill(&s)
+	}
+	applyCgroupLimits(&s)
+	return s
+}
+
+// round gathers the readings for one tick in the TUI as they arrive.
+type round struct {
+	id       int
+	gen      int // the tick chain the round belongs to
+	time     time.Time
+	started  time.Time
+	readings []reading
+	expected int
+}
+
+type readingMsg struct {
+	round   int
+	reading reading
+}
+
+func (c *collector) startRound(id int, t time.Time) (*round, tea.Cmd) {
+	r := &round{id: id, time: t, started: time.Now(), expected: len(c.sources)}
+	cmds := make([]tea.Cmd, len(c.sources))
+	for i, src := range c.sources {
+		cmds[i] = func() tea.Msg {
+			return readingMsg{round: id, reading: c.read(src, t)}
+		}
+	}
+	return r, tea.Batch(cmds...)
+}
+
+func (r *round) add(rd reading) bool {
+	r.readings = append(r.readings, rd)
+	return len(r.readings) == r.expected
+}
+
+func readCPU(ctx context.Context, _ time.Time) (func(*sample), error) {
+	total, err := cpu.PercentWithContext(ctx, 0, false)
+	if err != nil {
+		return nil, err
+	}
+	cores, _ := cpu.PercentWithContext(ctx, 0, true)
+	return func(s *sample) {
+		s.Metrics["CPU"] = total[0]
+		s.Cores = cores
+	}, nil
+}
+
+func readMemory(ctx context.Context, _ time.Time) (func(*sample), error) {
+	v, err := mem.VirtualMemoryWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return func(s *sample) {
+		s.Metrics["Memory"] = v.UsedPercent
+		s.Memory = v
+	}, nil
+}
+
+// readSwap hides the row on systems without swap.
+func readSwap(ctx context.Context, _ time.Time) (func(*sample), error) {
+	v, err := mem.SwapMemoryWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if v.Total == 0 {
+		return nil, errUnavailable
+	}
+	return func(s *sample) {
+		s.Metrics["Swap"] = v.UsedPercent
+		s.Swap = v
+	}, nil
+}
+
+func readLoad(ctx context.Context, _ time.Time) (func(*sample), error) {
+	v, err := load.AvgWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return func(s *sample) {
+		s.Metrics["Load"] = v.Load1
+		s.Load = v
+	}, nil
+}
+
+// diskReader reports how full the filesystem holding path is.
+func diskReader(path string) func(context.Context, time.Time) (func(*sample), error) {
+	return func(ctx context.Context, _ time.Time) (func(*sample), error) {
+		v, err := disk.UsageWithContext(ctx, path)
+		if err != nil {
+			return nil, err
+		}
+		return func(s *sample) {
+			s.Metrics["Disk"] = v.UsedPercent
+		}, nil
+	}
+}
+
+func readMounts(ctx context.Context, _ time.Time) (func(*sample), error) {
+	parts, err := disk.PartitionsWithContext(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	var mounts []mountUsage
+	for _, p := range parts {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if u, err := disk.UsageWithContext(ctx, p.Mountpoint); err == nil {
+			mounts = append(mounts, mountUsage{PartitionStat: p, Usage: u})
+		}
+	}
+	return func(s *sample) {
+		s.Mounts = mounts
+	}, nil
+}
+
+// readNetwork reports throughput in KB/s, summed over both directions.
+func (c *collector) readNetwork(ctx context.Context, t time.Time) (func(*sample), error) {
+	v, err := net.IOCountersWithContext(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	if len(v) == 0 {
+		return nil, errNoInterfaces
+	}
+
+	var sent, recv uint64
+	for _, nic := range v {
+		sent += nic.BytesSent
+		recv += nic.BytesRecv
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var delta uint64
+	if sent > c.last.netBytesSent {
+		delta += sent - c.last.netBytesSent
+	}
+	if recv > c.last.netBytesRecv {
+		delta += recv - c.last.netBytesRecv
+	}
+	netRate := 0.0
+	if elapsed := t.Sub(c.last.netTime).Seconds(); elapsed > 0 && !c.last.netTime.IsZero() {
+		netRate = float64(delta) / 1024 / elapsed
+	}
+	c.last.netBytesSent = sent
+	c.last.netBytesRecv = recv
+	c.last.netTime = t
+
+	return func(s *sample) {
+		s.Metrics["Network"] = netRate
+		s.Interfaces = v
+	}, nil
+}
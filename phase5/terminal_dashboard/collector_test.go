@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func fakeSource(name string, value float64) source {
+	return source{name: name, timeout: time.Second, read: func(context.Context, time.Time) (func(*sample), error) {
+		return func(s *sample) { s.Metrics[name] = value }, nil
+	}}
+}
+
+// hungSource blocks until release is closed, ignoring its context like a
+// stat on a dead NFS mount would.
+func hungSource(name string, release chan struct{}) source {
+	return source{name: name, timeout: 20 * time.Millisecond, read: func(context.Context, time.Time) (func(*sample), error) {
+		<-release
+		return func(s *sample) { s.Metrics[name] = 1 }, nil
+	}}
+}
+
+func TestCollectorTimeoutAndInFlight(t *testing.T) {
+	release := make(chan struct{})
+	c := newCollector(collectorConfig{})
+	c.sources = []source{fakeSource("CPU", 12), hungSource("Disk", release)}
+
+	s := c.collect(time.Now())
+	if s.Metrics["CPU"] != 12 {
+		t.Errorf("CPU = %v; want 12", s.Metrics["CPU"])
+	}
+	var te timeoutError
+	if !errors.As(s.Errors["Disk"], &te) {
+		t.Errorf("Disk error = %v; want timeout", s.Errors["Disk"])
+	}
+	if _, ok := s.Metrics["Disk"]; ok {
+		t.Error("a timed-out read must not fill the sample")
+	}
+
+	// The hung call is still running, so the next sample must not start
+	// another one.
+	s = c.collect(time.Now())
+	if !errors.Is(s.Errors["Disk"], errInFlight) {
+		t.Errorf("Disk error = %v; want in flight", s.Errors["Disk"])
+	}
+
+	close(release)
+	deadline := time.Now().Add(time.Second)
+	for {
+		s = c.collect(time.Now())
+		if s.Errors["Disk"] == nil || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if s.Errors["Disk"] != nil {
+		t.Errorf("Disk should recover once the hung call returns, got %v", s.Errors["Disk"])
+	}
+}
+
+func TestCollectorReconfigureKeepsState(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	c := newCollector(collectorConfig{})
+	c.readers = []source{fakeSource("CPU", 12), hungSource("Disk", release)}
+	c.configure(c.cfg)
+	c.collect(time.Now())
+	baseline := time.Unix(1700000000, 0)
+	c.last.netTime = baseline
+
+	c.configure(collectorConfig{timeouts: map[string]time.Duration{"CPU": 5 * time.Second}})
+	if c.sources[0].timeout != 5*time.Second {
+		t.Errorf("CPU timeout = %v; want 5s", c.sources[0].timeout)
+	}
+	if !c.last.netTime.Equal(baseline) {
+		t.Error("a reload should keep the network baseline")
+	}
+	// The Disk call from before the reload is still hung, so it must not
+	// be started again.
+	if s := c.collect(time.Now()); !errors.Is(s.Errors["Disk"], errInFlight) {
+		t.Errorf("Disk error = %v; want in flight", s.Errors["Disk"])
+	}
+
+	c.configure(collectorConfig{disabled: []string{"Disk"}})
+	if got := c.sourceNames(); len(got) != 1 || got[0] != "CPU" {
+		t.Errorf("sources = %v; want [CPU]", got)
+	}
+}
+
+func TestKnownSources(t *testing.T) {
+	if got := newCollector(collectorConfig{}).sourceNames(); !slices.Equal(got, knownSources) {
+		t.Errorf("sources = %v; knownSources = %v", got, knownSources)
+	}
+}
+
+func TestModelRoundShowsStaleIndicator(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	m := initialModel(options{})
+	m.width, m.height = 120, 40
+	m.collector.sources = []source{fakeSource("CPU", 30), hungSource("Disk", release)}
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		r, _ := m.collector.startRound(m.roundID+1, start.Add(time.Duration(i)*time.Second))
+		m.roundID, m.round = r.id, r
+		for _, src := range m.collector.sources {
+			next, _ := m.Update(readingMsg{round: r.id, reading: m.collector.read(src, r.time)})
+			m = next.(model)
+		}
+		if m.round != nil {
+			t.Fatal("round should complete once every source has reported")
+		}
+	}
+
+	if m.metrics["CPU"] != 30 {
+		t.Errorf("CPU = %v; want 30", m.metrics["CPU"])
+	}
+	if got := m.healthIndicator("Disk"); !strings.Contains(got, "hung") || !strings.Contains(got, "stale 2s") {
+		t.Errorf("Disk indicator = %q; want hung, stale 2s", got)
+	}
+	if got := m.healthIndicator("CPU"); got != "" {
+		t.Errorf("CPU indicator = %q; want none", got)
+	}
+	if n := len(m.events.entries); n != 1 {
+		t.Errorf("log has %d entries; a persistent failure should be logged once", n)
+	}
+}
+
+func TestSlowRoundBacksOffNextTick(t *testing.T) {
+	m := initialModel(options{interval: time.Second})
+	m.collector.sources = []source{fakeSource("CPU", 30)}
+
+	next, _ := m.Update(tickMsg{time: time.Now(), gen: m.sampler.gen})
+	m = next.(model)
+	if m.round == nil {
+		t.Fatal("a tick should start a round")
+	}
+	m.round.started = time.Now().Add(-1500 * time.Millisecond)
+
+	// A restarted chain's tick arriving mid-round must not start or
+	// schedule anything; the round carries on with the chain.
+	m.sampler.gen++
+	next, cmd := m.Update(tickMsg{time: time.Now(), gen: m.sampler.gen})
+	m = next.(model)
+	if cmd != nil {
+		t.Error("a tick during a round should not schedule another")
+	}
+
+	next, cmd = m.Update(readingMsg{round: m.round.id, reading: m.collector.read(m.collector.sources[0], time.Now())})
+	m = next.(model)
+	if m.sampler.delay != 3*time.Second {
+		t.Errorf("delay = %v; want 3s after a 1.5s round", m.sampler.delay)
+	}
+	if cmd == nil {
+		t.Error("a finished round should schedule the next tick")
+	}
+}
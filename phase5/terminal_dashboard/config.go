@@ -0,0 +1,370 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"gopkg.in/yaml.v3"
+)
+
+// configCheckInterval is how often the config file is checked for changes.
+const configCheckInterval = 2 * time.Second
+
+// dashboardConfig is the YAML config file:
+//
+//	interval: 2s
+//	collectors:
+//	  proc_root: /host/proc
+//	  sys_root: /host/sys
+//	  disk_path: /data
+//	  disabled: [Processes]
+//	  timeouts: {Mounts: 10s}
+//	panels:
+//	  - metric: CPU
+//	    color: "#04B575"
+//	    thresholds:
+//	      - above: 90
+//	        for: 30s
+//	  - metric: Network
+//	    unit: Mbit/s
+//
+// Panels appear in the order listed; metrics not listed are hidden.
+type dashboardConfig struct {
+	Interval   duration         `yaml:"interval"`
+	Collectors collectorOptions `yaml:"collectors"`
+	Panels     []panelConfig    `yaml:"panels"`
+}
+
+type collectorOptions struct {
+	ProcRoot   string              `yaml:"proc_root"`
+	CgroupRoot string              `yaml:"cgroup_root"`
+	SysRoot    string              `yaml:"sys_root"`
+	DiskPath   string              `yaml:"disk_path"`
+	Disabled   []string            `yaml:"disabled"`
+	Timeouts   map[string]duration `yaml:"timeouts"`
+}
+
+type panelConfig struct {
+	Metric     string      `yaml:"metric"`
+	Color      string      `yaml:"color"`
+	Unit       string      `yaml:"unit"`
+	Thresholds []threshold `yaml:"thresholds"`
+}
+
+// threshold is an alert rule on its panel's metric; see alertRule.
+type threshold struct {
+	Above      *float64 `yaml:"above"`
+	Below      *float64 `yaml:"below"`
+	For        duration `yaml:"for"`
+	Hysteresis *float64 `yaml:"hysteresis"`
+}
+
+// duration reads Go duration strings like "30s".
+type duration time.Duration
+
+func (d *duration) UnmarshalYAML(n *yaml.Node) error {
+	v, err := time.ParseDuration(n.Value)
+	if err != nil {
+		return fmt.Errorf("line %d: invalid duration %q", n.Line, n.Value)
+	}
+	*d = duration(v)
+	return nil
+}
+
+// loadConfig reads and validates a config file. Unknown keys are errors,
+// so a typo doesn't silently fall back to a default.
+func loadConfig(path string) (*dashboardConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg dashboardConfig
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("%s:\n%w", path, err)
+	}
+	return &cfg, nil
+}
+
+var colorPattern = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// validate reports every problem at once, so fixing a config doesn't take
+// one restart per mistake.
+func (c *dashboardConfig) validate() error {
+	var errs []error
+	addf := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if c.Interval != 0 && (time.Duration(c.Interval) < minInterval || time.Duration(c.Interval) > maxInterval) {
+		addf("interval: %v is outside %v-%v", time.Duration(c.Interval), minInterval, maxInterval)
+	}
+
+	for _, name := range c.Collectors.Disabled {
+		if !slices.Contains(knownSources, name) {
+			addf("collectors.disabled: unknown collector %q", name)
+		}
+	}
+	for _, name := range sortedKeys(c.Collectors.Timeouts) {
+		if !slices.Contains(knownSources, name) {
+			addf("collectors.timeouts: unknown collector %q", name)
+		} else if c.Collectors.Timeouts[name] <= 0 {
+			addf("collectors.timeouts.%s: must be positive", name)
+		}
+	}
+
+	seen := map[string]bool{}
+	for i, p := range c.Panels {
+		where := fmt.Sprintf("panels[%d]", i)
+		if p.Metric != "" {
+			where += " (" + p.Metric + ")"
+		}
+		info, isMetric := metricInfos[p.Metric]
+		switch {
+		case p.Metric == "":
+			addf("%s: metric is required", where)
+			continue
+		case !isMetric && !slices.Contains(extraPanels, p.Metric):
+			addf("%s: unknown metric; want one of %v", where, append(sortedKeys(metricInfos), extraPanels...))
+			continue
+		case seen[p.Metric]:
+			addf("%s: listed more than once", where)
+		}
+		seen[p.Metric] = true
+
+		if p.Color != "" && !validColor(p.Color) {
+			addf("%s: color %q is not #RGB, #RRGGBB or an ANSI color number", where, p.Color)
+		}
+		if p.Unit != "" {
+			if _, ok := displayUnits[info.unit][p.Unit]; !isMetric || !ok {
+				addf("%s: unit %q not available; want one of %v", where, p.Unit, sortedKeys(displayUnits[info.unit]))
+			}
+		}
+		if !isMetric && len(p.Thresholds) > 0 {
+			addf("%s: thresholds need a metric", where)
+		}
+		for j, t := range p.Thresholds {
+			if (t.Above == nil) == (t.Below == nil) {
+				addf("%s.thresholds[%d]: set exactly one of above or below", where, j)
+			}
+			if t.For < 0 {
+				addf("%s.thresholds[%d]: for must not be negative", where, j)
+			}
+			if t.Hysteresis != nil && *t.Hysteresis < 0 {
+				addf("%s.thresholds[%d]: hysteresis must not be negative", where, j)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func validColor(c string) bool {
+	if colorPattern.MatchString(c) {
+		return true
+	}
+	n, err := strconv.Atoi(c)
+	return err == nil && n >= 0 && n <= 255
+}
+
+// order is the panel order, or the default when no panels are listed.
+func (c *dashboardConfig) order() []string {
+	if len(c.Panels) == 0 {
+		return defaultPanels()
+	}
+	order := make([]string, len(c.Panels))
+	for i, p := range c.Panels {
+		order[i] = p.Metric
+	}
+	return order
+}
+
+func (c *dashboardConfig) infos() map[string]metricInfo {
+	infos := map[string]metricInfo{}
+	for _, p := range c.Panels {
+		if p.Unit == "" {
+			continue
+		}
+		info := metricInfos[p.Metric]
+		info.factor = displayUnits[info.unit][p.Unit]
+		info.unit = p.Unit
+		infos[p.Metric] = info
+	}
+	return infos
+}
+
+func (c *dashboardConfig) colors() map[string]lipgloss.Color {
+	colors := map[string]lipgloss.Color{}
+	for _, p := range c.Panels {
+		if p.Color != "" {
+			colors[p.Metric] = lipgloss.Color(p.Color)
+		}
+	}
+	return colors
+}
+
+func (c *dashboardConfig) alertRules(hysteresis float64) []alertRule {
+	var rules []alertRule
+	for _, p := range c.Panels {
+		for _, t := range p.Thresholds {
+			r := alertRule{Metric: p.Metric, For: time.Duration(t.For), Hysteresis: hysteresis}
+			if t.Above != nil {
+				r.Above, r.Threshold = true, *t.Above
+			} else {
+				r.Threshold = *t.Below
+			}
+			if t.Hysteresis != nil {
+				r.Hysteresis = *t.Hysteresis
+			}
+			rules = append(rules, r)
+		}
+	}
+	return rules
+}
+
+// configSource is where settings come from besides the config file: the
+// command line, whose explicitly set flags win over the file.
+type configSource struct {
+	path       string
+	collector  collectorConfig
+	interval   time.Duration
+	alerts     []alertRule
+	hysteresis float64
+	pinned     map[string]bool // flags set on the command line
+
+	modTime time.Time
+	size    int64
+}
+
+func (src *configSource) collectorConfig(c *dashboardConfig) collectorConfig {
+	cfg := src.collector
+	opts := c.Collectors
+	if opts.ProcRoot != "" && !src.pinned["proc-root"] {
+		cfg.procRoot = opts.ProcRoot
+	}
+	if opts.CgroupRoot != "" && !src.pinned["cgroup-root"] {
+		cfg.cgroupRoot = opts.CgroupRoot
+	}
+	if opts.SysRoot != "" && !src.pinned["sys-root"] {
+		cfg.sysRoot = opts.SysRoot
+	}
+	if opts.DiskPath != "" {
+		cfg.diskPath = opts.DiskPath
+	}
+	cfg.disabled = opts.Disabled
+	if len(opts.Timeouts) > 0 {
+		cfg.timeouts = map[string]time.Duration{}
+		for name, d := range opts.Timeouts {
+			cfg.timeouts[name] = time.Duration(d)
+		}
+	}
+	return cfg
+}
+
+func (src *configSource) sampleInterval(c *dashboardConfig) time.Duration {
+	if c.Interval == 0 || src.pinned["interval"] {
+		return src.interval
+	}
+	return time.Duration(c.Interval)
+}
+
+// rules are the -alert rules plus the config's thresholds, or the
+// defaults when there are neither.
+func (src *configSource) rules(c *dashboardConfig) []alertRule {
+	rules := append(slices.Clip(src.alerts), c.alertRules(src.hysteresis)...)
+	if len(rules) == 0 {
+		return defaultAlertRules(src.hysteresis)
+	}
+	return rules
+}
+
+type configCheckMsg struct{}
+
+// configMsg carries a reloaded config, or why it couldn't be loaded.
+type configMsg struct {
+	cfg *dashboardConfig
+	err error
+}
+
+// stat records the file's current version so check only reloads changes.
+func (src *configSource) stat() {
+	if fi, err := os.Stat(src.path); err == nil {
+		src.modTime, src.size = fi.ModTime(), fi.Size()
+	}
+}
+
+// reload loads the file again if its modification time or size changed
+// since the last look; changed is false when it hasn't.
+func (src *configSource) reload() (cfg *dashboardConfig, changed bool, err error) {
+	fi, err := os.Stat(src.path)
+	if err != nil || (fi.ModTime().Equal(src.modTime) && fi.Size() == src.size) {
+		return nil, false, nil
+	}
+	src.modTime, src.size = fi.ModTime(), fi.Size()
+	cfg, err = loadConfig(src.path)
+	return cfg, true, err
+}
+
+// check polls the file for the TUI. The check chain runs one command at a
+// time, so src isn't touched concurrently.
+func (src *configSource) check() tea.Cmd {
+	return tea.Tick(configCheckInterval, func(time.Time) tea.Msg {
+		cfg, changed, err := src.reload()
+		if !changed {
+			return configCheckMsg{}
+		}
+		return configMsg{cfg: cfg, err: err}
+	})
+}
+
+// reloadFailed is the log line for a config that didn't reload; the
+// running settings stay in effect until the file is fixed.
+func reloadFailed(err error) string {
+	return "config not reloaded, keeping the previous one: " + strings.ReplaceAll(err.Error(), "\n", "; ")
+}
+
+// applyConfig switches the dashboard to a (re)loaded config. Alerts whose
+// rule is unchanged keep their state, so a reload doesn't re-fire them.
+func (m model) applyConfig(cfg *dashboardConfig) (model, tea.Cmd) {
+	src := m.config
+	m.order = cfg.order()
+	m.infos = cfg.infos()
+	m.colors = cfg.colors()
+
+	var alerts []*alert
+	for _, r := range src.rules(cfg) {
+		a := &alert{rule: r}
+		for _, old := range m.alerts {
+			if old.rule == r {
+				a = old
+			}
+		}
+		alerts = append(alerts, a)
+	}
+	m.alerts = alerts
+
+	var cmd tea.Cmd
+	if m.collector != nil {
+		if cc := src.collectorConfig(cfg); !reflect.DeepEqual(cc, m.collector.cfg) {
+			m.collector.configure(cc)
+		}
+		if d := clampInterval(src.sampleInterval(cfg)); d != m.sampler.interval {
+			cmd = m.sampler.setInterval(d)
+		}
+	}
+	return m, cmd
+}
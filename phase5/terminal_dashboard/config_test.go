@@ -0,0 +1,172 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+func writeConfig(t *testing.T, dir, yaml string) string {
+	t.Helper()
+	path := filepath.Join(dir, "dashboard.yaml")
+	if err := os.WriteFile(path, []byte(yaml), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := writeConfig(t, t.TempDir(), `
+interval: 2s
+collectors:
+  disk_path: /data
+  disabled: [Processes]
+  timeouts: {Mounts: 10s}
+panels:
+  - metric: Network
+    unit: Mbit/s
+  - metric: CPU
+    color: "#00FFFF"
+    thresholds:
+      - above: 80
+        for: 1m
+      - below: 1
+        hysteresis: 0.5
+`)
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := cfg.order(); strings.Join(got, ",") != "Network,CPU" {
+		t.Errorf("order = %v; want [Network CPU]", got)
+	}
+	if got := cfg.colors()["CPU"]; got != lipgloss.Color("#00FFFF") {
+		t.Errorf("CPU color = %q; want #00FFFF", got)
+	}
+	// 125 KB/s is 1.024 Mbit/s.
+	if got := cfg.infos()["Network"].format(125); got != "  1.0 Mbit/s" {
+		t.Errorf("Network format(125) = %q; want \"  1.0 Mbit/s\"", got)
+	}
+
+	rules := cfg.alertRules(5)
+	want := []alertRule{
+		{Metric: "CPU", Above: true, Threshold: 80, For: time.Minute, Hysteresis: 5},
+		{Metric: "CPU", Threshold: 1, Hysteresis: 0.5},
+	}
+	if len(rules) != len(want) || rules[0] != want[0] || rules[1] != want[1] {
+		t.Errorf("alertRules = %+v; want %+v", rules, want)
+	}
+
+	src := &configSource{collector: collectorConfig{procRoot: "/proc"}, interval: time.Second}
+	cc := src.collectorConfig(cfg)
+	if cc.diskPath != "/data" || cc.timeouts["Mounts"] != 10*time.Second {
+		t.Errorf("collector config = %+v; want disk /data, Mounts timeout 10s", cc)
+	}
+	if names := newCollector(cc).sourceNames(); strings.Contains(strings.Join(names, ","), "Processes") {
+		t.Errorf("sources = %v; Processes should be disabled", names)
+	}
+	if got := src.sampleInterval(cfg); got != 2*time.Second {
+		t.Errorf("interval = %v; want 2s", got)
+	}
+	src.pinned = map[string]bool{"interval": true}
+	if got := src.sampleInterval(cfg); got != time.Second {
+		t.Errorf("interval with -interval set = %v; want 1s", got)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	path := writeConfig(t, t.TempDir(), `
+interval: 1h
+collectors:
+  disabled: [GPU]
+panels:
+  - metric: CPU
+    unit: KB/s
+    color: purple
+  - metric: Temperature
+  - metric: CPU
+    thresholds:
+      - above: 90
+        below: 10
+`)
+	_, err := loadConfig(path)
+	if err == nil {
+		t.Fatal("loadConfig succeeded; want validation errors")
+	}
+	for _, want := range []string{
+		"interval: 1h0m0s is outside",
+		`unknown collector "GPU"`,
+		`panels[0] (CPU): color "purple"`,
+		`panels[0] (CPU): unit "KB/s" not available`,
+		"panels[1] (Temperature): unknown metric",
+		"panels[2] (CPU): listed more than once",
+		"panels[2] (CPU).thresholds[0]: set exactly one of above or below",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error is missing %q:\n%v", want, err)
+		}
+	}
+
+	path = writeConfig(t, t.TempDir(), "panels:\n  - metrc: CPU\n")
+	if _, err := loadConfig(path); err == nil || !strings.Contains(err.Error(), "metrc") {
+		t.Errorf("unknown key error = %v; want it to name the key", err)
+	}
+}
+
+func TestConfigReload(t *testing.T) {
+	dir := t.TempDir()
+	path := writeConfig(t, dir, "panels:\n  - metric: CPU\n  - metric: Memory\n")
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := &configSource{path: path}
+	m := initialModel(options{config: src, file: cfg})
+	m.width, m.height = 120, 40
+	m, _ = m.applySample(sample{
+		Time:    time.Unix(1700000000, 0),
+		Metrics: map[string]float64{"CPU": 95, "Memory": 40, "Disk": 70},
+	})
+	if got := m.panels(); strings.Join(got, ",") != "CPU,Memory" {
+		t.Fatalf("panels = %v; want [CPU Memory]", got)
+	}
+
+	// A broken edit keeps the running config.
+	next, _ := m.Update(configMsg{err: os.ErrInvalid})
+	m = next.(model)
+	if got := m.panels(); strings.Join(got, ",") != "CPU,Memory" {
+		t.Errorf("panels after failed reload = %v; want [CPU Memory]", got)
+	}
+
+	path = writeConfig(t, dir, `
+panels:
+  - metric: Disk
+    color: "#00FFFF"
+    thresholds:
+      - above: 50
+  - metric: CPU
+`)
+	cfg, err = loadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next, _ = m.Update(configMsg{cfg: cfg})
+	m = next.(model)
+	if got := m.panels(); strings.Join(got, ",") != "Disk,CPU" {
+		t.Errorf("panels after reload = %v; want [Disk CPU]", got)
+	}
+	if got := m.colors["Disk"]; got != lipgloss.Color("#00FFFF") {
+		t.Errorf("Disk color = %q; want #00FFFF", got)
+	}
+	if len(m.alerts) != 1 || m.alerts[0].rule.Metric != "Disk" {
+		t.Errorf("alerts = %+v; want the Disk threshold only", m.alerts)
+	}
+	if !strings.Contains(m.View(), "config reloaded") {
+		t.Error("a successful reload should be logged")
+	}
+}
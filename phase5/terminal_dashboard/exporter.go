@@ -0,0 +1,346 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	promContentType        = "text/plain; version=0.0.4; charset=utf-8"
+	openMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+)
+
+// exporter serves the latest sample on /metrics in the Prometheus text
+// exposition format, or OpenMetrics when the scraper asks for it.
+type exporter struct {
+	mu     sync.RWMutex
+	latest sample
+}
+
+func (e *exporter) update(s sample) {
+	e.mu.Lock()
+	e.latest = s
+	e.mu.Unlock()
+}
+
+func (e *exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.mu.RLock()
+	s := e.latest
+	e.mu.RUnlock()
+
+	if s.Time.IsZero() {
+		http.Error(w, "no sample collected yet", http.StatusServiceUnavailable)
+		return
+	}
+
+	openMetrics := strings.Contains(r.Header.Get("Accept"), "application/openmetrics-text")
+	if openMetrics {
+		w.Header().Set("Content-Type", openMetricsContentType)
+	} else {
+		w.Header().Set("Content-Type", promContentType)
+	}
+	writeExposition(w, sampleFamilies(s), openMetrics)
+}
+
+type metricFamily struct {
+	name    string
+	help    string
+	typ     string // "gauge" or "counter"
+	unit    string
+	samples []metricSample
+}
+
+type metricSample struct {
+	labels [][2]string
+	value  float64
+}
+
+func (f *metricFamily) add(value float64, labels ...string) {
+	ms := metricSample{value: value}
+	for i := 0; i+1 < len(labels); i += 2 {
+		ms.labels = append(ms.labels, [2]string{labels[i], labels[i+1]})
+	}
+	f.samples = append(f.samples, ms)
+}
+
+// sampleFamilies maps a sample onto metric families. Percentages are
+// exported as 0-1 ratios and sizes in bytes, per Prometheus conventions.
+func sampleFamilies(s sample) []*metricFamily {
+	up := &metricFamily{name: "dashboard_collector_up", help: "Whether the last read of a collector succeeded.", typ: "gauge"}
+	for _, name := range s.Sources {
+		v := 1.0
+		if s.Errors[name] != nil {
+			v = 0
+		}
+		up.add(v, "collector", strings.ToLower(name))
+	}
+
+	cpuUsage := &metricFamily{name: "dashboard_cpu_usage_ratio", help: "CPU utilisation across all cores.", typ: "gauge", unit: "ratio"}
+	if s.Errors["CPU"] == nil {
+		cpuUsage.add(s.Metrics["CPU"] / 100)
+	}
+	coreUsage := &metricFamily{name: "dashboard_cpu_core_usage_ratio", help: "CPU utilisation per core.", typ: "gauge", unit: "ratio"}
+	for i, v := range s.Cores {
+		coreUsage.add(v/100, "core", strconv.Itoa(i))
+	}
+
+	memTotal := &metricFamily{name: "dashboard_memory_total_bytes", help: "Total physical memory.", typ: "gauge", unit: "bytes"}
+	memUsed := &metricFamily{name: "dashboard_memory_used_bytes", help: "Physical memory in use.", typ: "gauge", unit: "bytes"}
+	memAvail := &metricFamily{name: "dashboard_memory_available_bytes", help: "Memory available to new processes.", typ: "gauge", unit: "bytes"}
+	if m := s.Memory; m != nil {
+		memTotal.add(float64(m.Total))
+		memUsed.add(float64(m.Used))
+		memAvail.add(float64(m.Available))
+	}
+
+	swapTotal := &metricFamily{name: "dashboard_swap_total_bytes", help: "Total swap space.", typ: "gauge", unit: "bytes"}
+	swapUsed := &metricFamily{name: "dashboard_swap_used_bytes", help: "Swap space in use.", typ: "gauge", unit: "bytes"}
+	if sw := s.Swap; sw != nil {
+		swapTotal.add(float64(sw.Total))
+		swapUsed.add(float64(sw.Used))
+	}
+
+	load1 := &metricFamily{name: "dashboard_load1", help: "1-minute load average.", typ: "gauge"}
+	load5 := &metricFamily{name: "dashboard_load5", help: "5-minute load average.", typ: "gauge"}
+	load15 := &metricFamily{name: "dashboard_load15", help: "15-minute load average.", typ: "gauge"}
+	if l := s.Load; l != nil {
+		load1.add(l.Load1)
+		load5.add(l.Load5)
+		load15.add(l.Load15)
+	}
+
+	psiAvg := &metricFamily{name: "dashboard_pressure_avg10_ratio", help: "Share of time tasks were stalled on a resource over the last 10s.", typ: "gauge", unit: "ratio"}
+	psiTotal := &metricFamily{name: "dashboard_pressure_stalled_seconds", help: "Total time tasks were stalled on a resource.", typ: "counter", unit: "seconds"}
+	for _, res := range pressureResources {
+		p, ok := s.Pressure[res.metric]
+		if !ok {
+			continue
+		}
+		psiAvg.add(p.Some.Avg10/100, "resource", res.file, "kind", "some")
+		psiTotal.add(p.Some.Total.Seconds(), "resource", res.file, "kind", "some")
+		if p.Full != nil {
+			psiAvg.add(p.Full.Avg10/100, "resource", res.file, "kind", "full")
+			psiTotal.add(p.Full.Total.Seconds(), "resource", res.file, "kind", "full")
+		}
+	}
+
+	cgMemUsage := &metricFamily{name: "dashboard_cgroup_memory_working_set_bytes", help: "Memory in use by this cgroup, excluding reclaimable page cache.", typ: "gauge", unit: "bytes"}
+	cgMemLimit := &metricFamily{name: "dashboard_cgroup_memory_limit_bytes", help: "Memory limit of this cgroup.", typ: "gauge", unit: "bytes"}
+	cgCPULimit := &metricFamily{name: "dashboard_cgroup_cpu_limit_cores", help: "CPU quota of this cgroup in cores.", typ: "gauge"}
+	cgCPUUsage := &metricFamily{name: "dashboard_cgroup_cpu_usage_seconds", help: "CPU time used by this cgroup.", typ: "counter", unit: "seconds"}
+	sliceMem := &metricFamily{name: "dashboard_cgroup_slice_memory_working_set_bytes", help: "Memory in use by a top-level cgroup.", typ: "gauge", unit: "bytes"}
+	sliceCPU := &metricFamily{name: "dashboard_cgroup_slice_cpu_usage_seconds", help: "CPU time used by a top-level cgroup.", typ: "counter", unit: "seconds"}
+	if cg := s.Cgroup; cg != nil && cg.limited() {
+		cgMemUsage.add(float64(cg.MemoryUsage))
+		cgCPUUsage.add(cg.CPUUsage.Seconds())
+		if cg.MemoryLimit > 0 {
+			cgMemLimit.add(float64(cg.MemoryLimit))
+		}
+		if cg.CPULimit > 0 {
+			cgCPULimit.add(cg.CPULimit)
+		}
+	} else if cg != nil {
+		for _, sl := range cg.Slices {
+			sliceMem.add(float64(sl.MemoryUsage), "cgroup", sl.Name)
+			sliceCPU.add(sl.CPUUsage.Seconds(), "cgroup", sl.Name)
+		}
+	}
+
+	fsSize := &metricFamily{name: "dashboard_filesystem_size_bytes", help: "Filesystem size.", typ: "gauge", unit: "bytes"}
+	fsUsed := &metricFamily{name: "dashboard_filesystem_used_bytes", help: "Filesystem space in use.", typ: "gauge", unit: "bytes"}
+	fsFree := &metricFamily{name: "dashboard_filesystem_free_bytes", help: "Filesystem space free.", typ: "gauge", unit: "bytes"}
+	for _, mu := range s.Mounts {
+		labels := []string{"mountpoint", mu.Mountpoint, "device", mu.Device, "fstype", mu.Fstype}
+		fsSize.add(float64(mu.Usage.Total), labels...)
+		fsUsed.add(float64(mu.Usage.Used), labels...)
+		fsFree.add(float64(mu.Usage.Free), labels...)
+	}
+
+	rxBytes := &metricFamily{name: "dashboard_network_receive_bytes", help: "Bytes received.", typ: "counter", unit: "bytes"}
+	txBytes := &metricFamily{name: "dashboard_network_transmit_bytes", help: "Bytes transmitted.", typ: "counter", unit: "bytes"}
+	rxPackets := &metricFamily{name: "dashboard_network_receive_packets", help: "Packets received.", typ: "counter"}
+	txPackets := &metricFamily{name: "dashboard_network_transmit_packets", help: "Packets transmitted.", typ: "counter"}
+	rxErrs := &metricFamily{name: "dashboard_network_receive_errors", help: "Receive errors.", typ: "counter"}
+	txErrs := &metricFamily{name: "dashboard_network_transmit_errors", help: "Transmit errors.", typ: "counter"}
+	for _, nic := range s.Interfaces {
+		rxBytes.add(float64(nic.BytesRecv), "interface", nic.Name)
+		txBytes.add(float64(nic.BytesSent), "interface", nic.Name)
+		rxPackets.add(float64(nic.PacketsRecv), "interface", nic.Name)
+		txPackets.add(float64(nic.PacketsSent), "interface", nic.Name)
+		rxErrs.add(float64(nic.Errin), "interface", nic.Name)
+		txErrs.add(float64(nic.Errout), "interface", nic.Name)
+	}
+
+	temp := &metricFamily{name: "dashboard_temperature_celsius", help: "Hardware sensor temperature.", typ: "gauge", unit: "celsius"}
+	fan := &metricFamily{name: "dashboard_fan_speed_rpm", help: "Fan speed in revolutions per minute.", typ: "gauge"}
+	battery := &metricFamily{name: "dashboard_battery_capacity_ratio", help: "Battery charge remaining.", typ: "gauge", unit: "ratio"}
+	if st := s.Sensors; st != nil {
+		for _, t := range st.Temps {
+			temp.add(t.Celsius, "chip", t.Chip, "sensor", t.Label)
+		}
+		for _, f := range st.Fans {
+			fan.add(f.RPM, "chip", f.Chip, "sensor", f.Label)
+		}
+		for _, b := range st.Batteries {
+			battery.add(b.Capacity/100, "battery", b.Name)
+		}
+	}
+
+	scrape := &metricFamily{name: "dashboard_last_sample_timestamp_seconds", help: "When the exported sample was collected.", typ: "gauge", unit: "seconds"}
+	scrape.add(float64(s.Time.UnixNano()) / float64(time.Second))
+
+	return []*metricFamily{
+		up, cpuUsage, coreUsage,
+		memTotal, memUsed, memAvail,
+		swapTotal, swapUsed,
+		load1, load5, load15,
+		psiAvg, psiTotal,
+		cgMemUsage, cgMemLimit, cgCPULimit, cgCPUUsage, sliceMem, sliceCPU,
+		fsSize, fsUsed, fsFree,
+		rxBytes, txBytes, rxPackets, txPackets, rxErrs, txErrs,
+		temp, fan, battery,
+		scrape,
+	}
+}
+
+func writeExposition(w io.Writer, families []*metricFamily, openMetrics bool) {
+	for _, f := range families {
+		if len(f.samples) == 0 {
+			continue
+		}
+
+		// Prometheus text format puts _total in the family name of counters;
+		// OpenMetrics names the family without it and suffixes each sample.
+		family, sampleName := f.name, f.name
+		if f.typ == "counter" {
+			sampleName += "_total"
+			if !openMetrics {
+				family = sampleName
+			}
+		}
+
+		fmt.Fprintf(w, "# HELP %s %s\n", family, escapeHelp(f.help))
+		fmt.Fprintf(w, "# TYPE %s %s\n", family, f.typ)
+		if openMetrics && f.unit != "" {
+			fmt.Fprintf(w, "# UNIT %s %s\n", family, f.unit)
+		}
+		for _, ms := range f.samples {
+			fmt.Fprintf(w, "%s%s %s\n", sampleName, formatLabels(ms.labels), formatValue(ms.value))
+		}
+	}
+	if openMetrics {
+		fmt.Fprintln(w, "# EOF")
+	}
+}
+
+func formatLabels(labels [][2]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	parts := make([]string, len(labels))
+	for i, l := range labels {
+		parts[i] = l[0] + `="` + escapeLabel(l[1]) + `"`
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func newMetricsServer(addr string, exp *exporter) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", exp)
+	mux.Handle(samplePath, agentHandler{exp: exp, host: agentHostname()})
+	return &http.Server{
+		Addr:         addr,
+		Handler:      mux,
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 10 * time.Second,
+	}
+}
+
+// runHeadless is agent mode: it collects on the same path as the TUI but
+// only feeds the exporter (and the recorder, if any) until the server stops.
+// With a config file it picks up collector and interval changes the way the
+// TUI does.
+func runHeadless(srv *http.Server, exp *exporter, rec *recorder, src *configSource, cfg collectorConfig, interval time.Duration) error {
+	c := newCollector(cfg)
+	exp.update(c.collect(time.Now()))
+
+	var reload <-chan time.Time
+	if src != nil {
+		ticker := time.NewTicker(configCheckInterval)
+		defer ticker.Stop()
+		reload = ticker.C
+	}
+
+	done := make(chan struct{})
+	go func() {
+		timer := time.NewTimer(interval)
+		defer timer.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-reload:
+				file, changed, err := src.reload()
+				switch {
+				case !changed:
+				case err != nil:
+					fmt.Fprintln(os.Stderr, reloadFailed(err))
+				default:
+					c.configure(src.collectorConfig(file))
+					interval = clampInterval(src.sampleInterval(file))
+					fmt.Fprintln(os.Stderr, "config reloaded from "+src.path)
+				}
+			case t := <-timer.C:
+				s := c.collect(t)
+				timer.Reset(nextDelay(interval, time.Since(t)))
+				exp.update(s)
+				if rec != nil {
+					if err := rec.write(s); err != nil {
+						fmt.Fprintf(os.Stderr, "record: %v\n", err)
+					}
+				}
+			}
+		}
+	}()
+	defer close(done)
+
+	fmt.Printf("Serving metrics on http://%s/metrics\n", srv.Addr)
+	return srv.ListenAndServe()
+}
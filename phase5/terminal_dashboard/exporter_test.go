@@ -0,0 +1,114 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/disk"
+	"github.com/shirou/gopsutil/v4/mem"
+	"github.com/shirou/gopsutil/v4/net"
+)
+
+func testSample() sample {
+	return sample{
+		Time:    time.Unix(1_700_000_000, 0),
+		Metrics: map[string]float64{"CPU": 50, "Memory": 25, "Disk": 80, "Network": 1},
+		Errors:  map[string]error{"Network": errors.New("boom")},
+		Sources: []string{"CPU", "Memory", "Disk", "Mounts", "Network"},
+		Cores:   []float64{40, 60},
+		Memory:  &mem.VirtualMemoryStat{Total: 1000, Used: 250, Available: 750},
+		Mounts: []mountUsage{{
+			PartitionStat: disk.PartitionStat{Device: "/dev/sda1", Mountpoint: "/", Fstype: "ext4"},
+			Usage:         &disk.UsageStat{Total: 100, Used: 80, Free: 20},
+		}},
+		Interfaces: []net.IOCountersStat{{Name: `eth"0`, BytesRecv: 10, BytesSent: 20}},
+	}
+}
+
+func scrape(t *testing.T, exp *exporter, accept string) (string, string) {
+	t.Helper()
+	srv := httptest.NewServer(newMetricsServer("", exp).Handler)
+	defer srv.Close()
+
+	req, _ := http.NewRequest("GET", srv.URL+"/metrics", nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.Header.Get("Content-Type"), string(body)
+}
+
+func TestExporterPrometheusFormat(t *testing.T) {
+	exp := &exporter{}
+	exp.update(testSample())
+
+	ct, body := scrape(t, exp, "")
+	if ct != promContentType {
+		t.Errorf("Content-Type = %q; want %q", ct, promContentType)
+	}
+
+	for _, want := range []string{
+		"# TYPE dashboard_cpu_usage_ratio gauge\ndashboard_cpu_usage_ratio 0.5\n",
+		`dashboard_cpu_core_usage_ratio{core="1"} 0.6`,
+		"dashboard_memory_used_bytes 250\n",
+		`dashboard_filesystem_used_bytes{mountpoint="/",device="/dev/sda1",fstype="ext4"} 80`,
+		"# TYPE dashboard_network_receive_bytes_total counter\n",
+		`dashboard_network_receive_bytes_total{interface="eth\"0"} 10`,
+		`dashboard_collector_up{collector="network"} 0`,
+		`dashboard_collector_up{collector="cpu"} 1`,
+		`dashboard_collector_up{collector="mounts"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("exposition missing %q\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "# EOF") || strings.Contains(body, "# UNIT") {
+		t.Error("Prometheus text format should not contain OpenMetrics-only lines")
+	}
+}
+
+func TestExporterOpenMetricsFormat(t *testing.T) {
+	exp := &exporter{}
+	exp.update(testSample())
+
+	ct, body := scrape(t, exp, "application/openmetrics-text;version=1.0.0,text/plain;q=0.5")
+	if ct != openMetricsContentType {
+		t.Errorf("Content-Type = %q; want %q", ct, openMetricsContentType)
+	}
+	for _, want := range []string{
+		"# TYPE dashboard_network_receive_bytes counter\n",
+		"# UNIT dashboard_network_receive_bytes bytes\n",
+		`dashboard_network_receive_bytes_total{interface="eth\"0"} 10`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("exposition missing %q\n%s", want, body)
+		}
+	}
+	if !strings.HasSuffix(body, "# EOF\n") {
+		t.Error("OpenMetrics exposition must end with # EOF")
+	}
+}
+
+func TestExporterNoSample(t *testing.T) {
+	srv := httptest.NewServer(&exporter{})
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("status = %d; want 503", resp.StatusCode)
+	}
+}
@@ -0,0 +1,431 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+const processesPanel = "Processes"
+
+// maxDetailHeight caps the plot rows of the focused panel's detail view.
+const maxDetailHeight = 12
+
+// panels lists the focusable panels in display order: the metrics that
+// have been collected, Sensors when the machine has any, and Processes
+// once there is a process list.
+func (m model) panels() []string {
+	var panels []string
+	metrics := m.visibleMetrics()
+	for _, panel := range m.order {
+		switch {
+		case panel == processesPanel && m.last.Processes != nil,
+			panel == sensorsPanel && m.last.Sensors != nil,
+			slices.Contains(metrics, panel):
+			panels = append(panels, panel)
+		}
+	}
+	return panels
+}
+
+// focused returns the focused panel, falling back to the first one if the
+// focused panel has disappeared (or nothing was focused yet).
+func (m model) focused() string {
+	panels := m.panels()
+	for _, p := range panels {
+		if p == m.focus {
+			return p
+		}
+	}
+	if len(panels) == 0 {
+		return ""
+	}
+	return panels[0]
+}
+
+func (m model) moveFocus(delta int) model {
+	panels := m.panels()
+	if len(panels) == 0 {
+		return m
+	}
+	i := 0
+	for j, p := range panels {
+		if p == m.focused() {
+			i = j
+		}
+	}
+	m.focus = panels[((i+delta)%len(panels)+len(panels))%len(panels)]
+	return m
+}
+
+// focusKey handles the keys that move focus or act on the focused panel.
+// Arrows only move focus where they aren't already seek keys.
+func (m model) focusKey(key string) (model, bool) {
+	arrows := m.replay == nil
+	switch {
+	case key == "tab" || key == "c":
+		return m.moveFocus(1), true
+	case key == "shift+tab":
+		return m.moveFocus(-1), true
+	case arrows && key == "right":
+		return m.moveFocus(1), true
+	case arrows && key == "left":
+		return m.moveFocus(-1), true
+	case arrows && (key == "down" || key == "up"):
+		cols := m.frame().layout.cols
+		i, panels := 0, m.panels()
+		for j, p := range panels {
+			if p == m.focused() {
+				i = j
+			}
+		}
+		if key == "up" {
+			cols = -cols
+		}
+		if i+cols >= 0 && i+cols < len(panels) {
+			m.focus = panels[i+cols]
+		}
+		return m, true
+	case key == "v":
+		m.breakdown = !m.breakdown
+		return m, true
+	case m.focused() == processesPanel && key == "o":
+		m.procSort = (m.procSort + 1) % len(procSorts)
+		return m, true
+	case m.focused() == processesPanel && key == "r":
+		m.procReverse = !m.procReverse
+		return m, true
+	}
+	return m, false
+}
+
+func (m model) mouse(msg tea.MouseMsg) model {
+	if msg.Action != tea.MouseActionPress || msg.Button != tea.MouseButtonLeft {
+		return m
+	}
+	if p, ok := m.frame().panelAt(msg.X, msg.Y); ok {
+		m.focus = p
+	}
+	return m
+}
+
+// frame is where everything goes for the current terminal size. View draws
+// from it and mouse clicks are mapped back to panels through it.
+type frame struct {
+	title    string
+	timeBar  string
+	slices   string
+	panels   []string
+	layout   layout
+	detail   int // plot rows of the detail view, 0 when there's no room
+	gridTop  int
+	gridLeft int
+	width    int
+}
+
+func (m model) frame() frame {
+	f := frame{width: max(1, m.width-2), panels: m.panels()}
+
+	heading := "🖥️  System Monitor Dashboard"
+	if m.host != "" {
+		heading += " — " + m.host
+	}
+	if summary := cgroupSummary(m.last.Cgroup); summary != "" {
+		heading += " · " + summary
+	}
+	f.title = lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("#FAFAFA")).
+		Background(lipgloss.Color("#7D56F4")).
+		Padding(0, 1).
+		Width(m.width).
+		Align(lipgloss.Center).
+		Render(heading)
+
+	window := zoomWindows[m.zoom]
+	timeText := m.time + "  window " + windowLabel(window) + " (z/Z zoom, tab/click focus, s snapshot)"
+	if m.replay != nil {
+		timeText = m.replay.status() + "  " + timeText +
+			"\nspace play/pause  ←/→ seek 10s  shift+←/→ 1m  +/- speed  q quit"
+	} else if m.collector != nil {
+		timeText += "\n" + m.sampler.status() + "  p pause  +/- interval  q quit"
+	}
+	f.timeBar = lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#626262")).
+		Width(m.width).
+		Align(lipgloss.Center).
+		Render(timeText)
+
+	// Everything but the panels and the detail view: title, time bar,
+	// spacing, the slices panel and the event log.
+	f.slices = m.slicePanel(f.width)
+	chrome := lipgloss.Height(f.title) + 6 + lipgloss.Height(f.timeBar)
+	if f.slices != "" {
+		chrome += lipgloss.Height(f.slices) + 1
+	}
+	if n := len(m.events.entries); n > 0 {
+		chrome += n + 2
+	}
+
+	f.layout = computeLayout(f.width, m.height-chrome, len(f.panels))
+	// The detail view has a heading and two lines of axes on top of its
+	// plot rows. With no panels there is nothing to focus, so no detail.
+	if h := m.height - chrome - f.layout.height(len(f.panels)) - 3; h >= 3 && !f.layout.compact && len(f.panels) > 0 {
+		f.detail = min(h, maxDetailHeight)
+	}
+
+	detailLines := 1
+	if f.detail > 0 {
+		detailLines = f.detail + 3
+	}
+	f.gridTop = lipgloss.Height(f.title) + 1 + detailLines + 1
+	f.gridLeft = (m.width - f.width) / 2
+	return f
+}
+
+// panelAt maps a screen position to the panel drawn there.
+func (f frame) panelAt(x, y int) (string, bool) {
+	l := f.layout
+	row, col := y-f.gridTop, x-f.gridLeft
+	if row < 0 || col < 0 {
+		return "", false
+	}
+	if !l.compact {
+		if row%(fullPanelHeight+1) == fullPanelHeight {
+			return "", false
+		}
+		row /= fullPanelHeight + 1
+	}
+	if col%(l.colWidth+panelGap) >= l.colWidth {
+		return "", false
+	}
+	col /= l.colWidth + panelGap
+	i := row*l.cols + col
+	if col >= l.cols || i >= len(f.panels) {
+		return "", false
+	}
+	return f.panels[i], true
+}
+
+// panelKeys are the hints shown on the focused panel's detail view.
+func (m model) panelKeys(panel string) string {
+	switch {
+	case panel == processesPanel:
+		return fmt.Sprintf("o sort (%s)  r reverse", procSorts[m.procSort].name)
+	case panel == sensorsPanel:
+		return ""
+	case m.breakdownView(panel, 1, 1) != "":
+		view := "breakdown"
+		if m.breakdown {
+			view = "chart"
+		}
+		return "v " + view + "  z/Z zoom"
+	}
+	return "z/Z zoom"
+}
+
+// detailView is the focused panel expanded: its chart, or a breakdown
+// behind the headline number when one is toggled on, always exactly
+// f.detail+3 lines tall.
+func (m model) detailView(f frame) string {
+	panel := m.focused()
+	window := zoomWindows[m.zoom]
+
+	title := panel + " — last " + windowLabel(window)
+	switch panel {
+	case processesPanel:
+		title = fmt.Sprintf("%s — %d running", panel, len(m.last.Processes))
+	case sensorsPanel:
+		title = panel
+	}
+	heading := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#7D56F4")).Render(title)
+	hints := lipgloss.NewStyle().Foreground(lipgloss.Color("#626262")).Render(m.panelKeys(panel))
+	if gap := f.width - lipgloss.Width(heading) - lipgloss.Width(hints); gap >= 2 {
+		heading += strings.Repeat(" ", gap) + hints
+	}
+
+	rows := f.detail + 2
+	var body string
+	switch {
+	case panel == processesPanel:
+		body = m.processTable(f.width, rows)
+	case panel == sensorsPanel:
+		body = m.sensorTable(f.width, rows)
+	case m.breakdown:
+		body = m.breakdownView(panel, f.width, rows)
+	}
+	if body == "" {
+		body = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#04B575")).
+			Render(m.chartLines(panel, window, f.width, f.detail))
+	}
+
+	return lipgloss.NewStyle().
+		Width(f.width).
+		Height(rows + 1).
+		MaxHeight(rows + 1).
+		Render(heading + "\n" + body)
+}
+
+// breakdownView lists what makes up a metric from the latest sample, in at
+// most rows lines, or "" when there's nothing more to show than the chart.
+func (m model) breakdownView(metric string, width, rows int) string {
+	s := m.last
+	var lines []string
+	switch metric {
+	case "CPU":
+		if len(s.Cores) == 0 {
+			return ""
+		}
+		const cell = 26
+		cols := max(1, width/cell)
+		var row []string
+		for i, v := range s.Cores {
+			row = append(row, fmt.Sprintf("%-*s", cell, fmt.Sprintf("core %-2d %s %s", i, miniBar(v, 0, 100, 10), formatMetric("CPU", v))))
+			if len(row) == cols || i == len(s.Cores)-1 {
+				lines = append(lines, strings.Join(row, ""))
+				row = nil
+			}
+		}
+	case "Memory":
+		if s.Memory == nil {
+			return ""
+		}
+		v := s.Memory
+		lines = append(lines,
+			fmt.Sprintf("%-10s %12s", "total", formatBytes(v.Total)),
+			fmt.Sprintf("%-10s %12s  %s", "used", formatBytes(v.Used), miniBar(v.UsedPercent, 0, 100, 20)),
+			fmt.Sprintf("%-10s %12s", "available", formatBytes(v.Available)),
+			fmt.Sprintf("%-10s %12s", "cached", formatBytes(v.Cached)),
+			fmt.Sprintf("%-10s %12s", "buffers", formatBytes(v.Buffers)))
+		if sw := s.Swap; sw != nil {
+			lines = append(lines, fmt.Sprintf("%-10s %12s of %s", "swap", formatBytes(sw.Used), formatBytes(sw.Total)))
+		}
+		if cg := s.Cgroup; cg != nil && cg.MemoryLimit > 0 {
+			lines = append(lines, fmt.Sprintf("%-10s %12s of %s", "cgroup", formatBytes(cg.MemoryUsage), formatBytes(cg.MemoryLimit)))
+		}
+	case "Network":
+		if len(s.Interfaces) == 0 {
+			return ""
+		}
+		lines = append(lines, fmt.Sprintf("%-12s %12s %12s %10s %10s", "interface", "received", "sent", "rx errs", "tx errs"))
+		for _, nic := range s.Interfaces {
+			lines = append(lines, fmt.Sprintf("%-12s %12s %12s %10d %10d", nic.Name,
+				formatBytes(nic.BytesRecv), formatBytes(nic.BytesSent), nic.Errin, nic.Errout))
+		}
+	case "Disk":
+		if len(s.Mounts) == 0 {
+			return ""
+		}
+		for _, mu := range s.Mounts {
+			lines = append(lines, fmt.Sprintf("%-20s %-8s %10s of %-10s %s %s",
+				mu.Mountpoint, mu.Fstype, formatBytes(mu.Usage.Used), formatBytes(mu.Usage.Total),
+				miniBar(mu.Usage.UsedPercent, 0, 100, 10), formatMetric("Disk", mu.Usage.UsedPercent)))
+		}
+	case "Load":
+		if s.Load == nil {
+			return ""
+		}
+		lines = append(lines, fmt.Sprintf("1m %.2f   5m %.2f   15m %.2f", s.Load.Load1, s.Load.Load5, s.Load.Load15))
+	case "PSI cpu", "PSI mem", "PSI io":
+		p, ok := s.Pressure[metric]
+		if !ok {
+			return ""
+		}
+		lines = append(lines, fmt.Sprintf("%-5s %7s %7s %7s %12s", "", "avg10", "avg60", "avg300", "total"))
+		lines = append(lines, fmt.Sprintf("%-5s %6.2f%% %6.2f%% %6.2f%% %12v", "some", p.Some.Avg10, p.Some.Avg60, p.Some.Avg300, p.Some.Total))
+		if f := p.Full; f != nil {
+			lines = append(lines, fmt.Sprintf("%-5s %6.2f%% %6.2f%% %6.2f%% %12v", "full", f.Avg10, f.Avg60, f.Avg300, f.Total))
+		}
+	default:
+		return ""
+	}
+	if len(lines) > rows {
+		lines = append(lines[:rows-1], fmt.Sprintf("… %d more", len(lines)-rows+1))
+	}
+	for i, line := range lines {
+		lines[i] = ansi.Truncate(line, width, "…")
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (m model) processTable(width, rows int) string {
+	if len(m.last.Processes) == 0 {
+		return "no processes"
+	}
+	procs := sortProcs(m.last.Processes, m.procSort, m.procReverse)
+	nameWidth := max(8, width-30)
+	lines := []string{lipgloss.NewStyle().Bold(true).Render(
+		fmt.Sprintf("%7s  %-*s %7s %12s", "PID", nameWidth, "NAME", "CPU%", "RSS"))}
+	for _, p := range procs[:min(len(procs), rows-1)] {
+		lines = append(lines, fmt.Sprintf("%7d  %-*s %7.1f %12s",
+			p.PID, nameWidth, ansi.Truncate(p.Name, nameWidth, "…"), p.CPU, formatBytes(p.RSS)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// processPanel is the Processes entry in the grid: a count and the busiest
+// processes.
+func (m model) processPanel(l layout) string {
+	procs := sortProcs(m.last.Processes, 0, false)
+	line := fmt.Sprintf("%s %d processes", m.panelLabel(processesPanel, lipgloss.Color("#04B575")), len(procs))
+	if l.compact {
+		return line + m.staleMark(processesPanel)
+	}
+	lines := m.withIndicator(processesPanel, []string{line}, l)
+	for _, p := range procs[:min(fullPanelHeight-len(lines), len(procs))] {
+		lines = append(lines, lipgloss.NewStyle().Foreground(lipgloss.Color("#626262")).Render(
+			ansi.Truncate(fmt.Sprintf("%5.1f%%  %s", p.CPU, p.Name), l.colWidth, "…")))
+	}
+	for len(lines) < fullPanelHeight {
+		lines = append(lines, "")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// staleMark is the compact layout's sign that panel's source is failing.
+func (m model) staleMark(panel string) string {
+	if m.healthIndicator(panel) == "" {
+		return ""
+	}
+	return " " + lipgloss.NewStyle().Foreground(lipgloss.Color("#FFB86C")).Render("⚠")
+}
+
+// withIndicator puts the health indicator of a failing panel source under
+// the panel's first line, dropping lines that no longer fit.
+func (m model) withIndicator(panel string, lines []string, l layout) []string {
+	indicator := m.healthIndicator(panel)
+	if indicator == "" {
+		return lines
+	}
+	warn := lipgloss.NewStyle().Foreground(lipgloss.Color("#FFB86C")).
+		Render(ansi.Truncate(indicator, l.colWidth, "…"))
+	lines = append([]string{lines[0], warn}, lines[1:]...)
+	return lines[:min(len(lines), fullPanelHeight)]
+}
+
+// panelLabel is a panel's name at the start of its first line, highlighted
+// when the panel has focus.
+func (m model) panelLabel(panel string, color lipgloss.Color) string {
+	label := fmt.Sprintf("%-8s", panel)
+	switch {
+	case panel == m.focused():
+		return lipgloss.NewStyle().Bold(true).
+			Foreground(lipgloss.Color("#FAFAFA")).
+			Background(lipgloss.Color("#7D56F4")).
+			Render(label)
+	case m.alertState(panel) == alertFiring:
+		return lipgloss.NewStyle().Bold(true).Foreground(color).Render(label)
+	}
+	return label
+}
+
+func miniBar(v, lo, hi float64, width int) string {
+	filled := int(math.Round(float64(width) * math.Min(math.Max((v-lo)/(hi-lo), 0), 1)))
+	return strings.Repeat("█", filled) + strings.Repeat("░", width-filled)
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+func focusModel(width, height int) model {
+	m := initialModel(options{})
+	m.width, m.height = width, height
+	m, _ = m.applySample(sample{
+		Time:    time.Unix(1700000000, 0),
+		Metrics: map[string]float64{"CPU": 20, "Memory": 40, "Network": 5, "Disk": 70},
+		Cores:   []float64{10, 30},
+		Processes: []procInfo{
+			{PID: 1, Name: "init", CPU: 0.1, RSS: 8 << 20},
+			{PID: 300, Name: "postgres", CPU: 12, RSS: 512 << 20},
+			{PID: 42, Name: "sshd", CPU: 1.5, RSS: 4 << 20},
+		},
+	})
+	return m
+}
+
+func press(m model, keys ...string) model {
+	for _, k := range keys {
+		var msg tea.KeyMsg
+		switch k {
+		case "tab":
+			msg = tea.KeyMsg{Type: tea.KeyTab}
+		case "shift+tab":
+			msg = tea.KeyMsg{Type: tea.KeyShiftTab}
+		case "down":
+			msg = tea.KeyMsg{Type: tea.KeyDown}
+		case "right":
+			msg = tea.KeyMsg{Type: tea.KeyRight}
+		default:
+			msg = tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)}
+		}
+		next, _ := m.Update(msg)
+		m = next.(model)
+	}
+	return m
+}
+
+func TestFocusKeys(t *testing.T) {
+	m := focusModel(120, 60)
+	if got := m.focused(); got != "CPU" {
+		t.Fatalf("initial focus = %q; want CPU", got)
+	}
+
+	tests := []struct {
+		keys []string
+		want string
+	}{
+		{[]string{"tab"}, "Memory"},
+		{[]string{"tab", "tab", "tab", "tab"}, processesPanel},
+		{[]string{"tab", "tab", "tab", "tab", "tab"}, "CPU"},
+		{[]string{"shift+tab"}, processesPanel},
+		{[]string{"right", "right"}, "Network"},
+	}
+	for _, tt := range tests {
+		if got := press(m, tt.keys...).focused(); got != tt.want {
+			t.Errorf("after %v focus = %q; want %q", tt.keys, got, tt.want)
+		}
+	}
+
+	// Down moves a whole row, however many columns the layout has.
+	cols := m.frame().layout.cols
+	if got, want := press(m, "down").focused(), m.panels()[cols]; got != want {
+		t.Errorf("after down focus = %q; want %q (%d columns)", got, want, cols)
+	}
+}
+
+func TestFocusMouse(t *testing.T) {
+	m := focusModel(120, 20)
+	f := m.frame()
+	if f.layout.cols < 2 || f.layout.compact {
+		t.Fatalf("layout = %+v; want at least two full columns", f.layout)
+	}
+
+	click := func(x, y int) model {
+		next, _ := m.Update(tea.MouseMsg{X: x, Y: y, Action: tea.MouseActionPress, Button: tea.MouseButtonLeft})
+		return next.(model)
+	}
+	// Second column, second row of panels, on its sparkline line.
+	x := f.gridLeft + f.layout.colWidth + panelGap + 3
+	y := f.gridTop + fullPanelHeight + 1 + 2
+	if got, want := click(x, y).focused(), f.panels[f.layout.cols+1]; got != want {
+		t.Errorf("click focus = %q; want %q", got, want)
+	}
+	// The gap between columns and the blank line between rows hit nothing.
+	if got := click(f.gridLeft+f.layout.colWidth, f.gridTop).focused(); got != "CPU" {
+		t.Errorf("click on gap focused %q", got)
+	}
+	if got := click(x, f.gridTop+fullPanelHeight).focused(); got != "CPU" {
+		t.Errorf("click between rows focused %q", got)
+	}
+}
+
+func TestDetailView(t *testing.T) {
+	m := focusModel(120, 60)
+	f := m.frame()
+	if f.detail == 0 {
+		t.Fatal("no room for the detail view")
+	}
+
+	if h := lipgloss.Height(m.detailView(f)); h != f.detail+3 {
+		t.Errorf("chart detail is %d lines; want %d", h, f.detail+3)
+	}
+	cores := press(m, "v").detailView(f)
+	if h := lipgloss.Height(cores); h != f.detail+3 || !strings.Contains(cores, "core 1") {
+		t.Errorf("CPU breakdown (%d lines) should list cores:\n%s", h, cores)
+	}
+
+	procs := press(m, "shift+tab")
+	table := procs.detailView(f)
+	if strings.Index(table, "postgres") > strings.Index(table, "sshd") {
+		t.Errorf("processes should be sorted by CPU:\n%s", table)
+	}
+	table = press(procs, "o", "o").detailView(f)
+	if !(strings.Index(table, "init") < strings.Index(table, "sshd") && strings.Index(table, "sshd") < strings.Index(table, "postgres")) {
+		t.Errorf("processes should be sorted by pid:\n%s", table)
+	}
+	if !strings.Contains(table, "o sort (pid)") {
+		t.Errorf("detail view should show the Processes key hints:\n%s", table)
+	}
+}
+
+func TestViewBeforeFirstSample(t *testing.T) {
+	m := initialModel(options{})
+	next, _ := m.Update(tea.WindowSizeMsg{Width: 120, Height: 50})
+	m = next.(model)
+	if m.focused() != "" {
+		t.Errorf("focused = %q with no panels; want none", m.focused())
+	}
+	if f := m.frame(); f.detail != 0 {
+		t.Errorf("detail height = %d with no panels; want 0", f.detail)
+	}
+	m.View()
+}
+
+func TestViewMetricThatHasOnlyFailed(t *testing.T) {
+	m := initialModel(options{})
+	next, _ := m.Update(tea.WindowSizeMsg{Width: 120, Height: 50})
+	m = next.(model)
+	m, _ = m.applySample(sample{
+		Time:    time.Unix(1700000000, 0),
+		Metrics: map[string]float64{},
+		Errors:  map[string]error{"Disk": errors.New("permission denied")},
+	})
+	if m.focused() != "Disk" {
+		t.Fatalf("focused = %q; want Disk", m.focused())
+	}
+	if view := m.View(); !strings.Contains(view, "⚠ error") {
+		t.Errorf("view doesn't show the Disk error:\n%s", view)
+	}
+	m.snapshot().svg()
+}
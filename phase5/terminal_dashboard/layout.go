@@ -0,0 +1,97 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	// minPanelWidth fits a label, a short bar, a value and the stats line.
+	minPanelWidth = 36
+	maxColumns    = 3
+	panelGap      = 2
+	// A full panel is a bar line, a stats line and a sparkline, with a
+	// blank line between rows of panels; a compact panel is the bar line.
+	fullPanelHeight = 3
+	maxBarWidth     = 40
+)
+
+// layout is how metric panels are arranged for the current terminal size.
+type layout struct {
+	cols     int
+	colWidth int
+	compact  bool
+}
+
+// computeLayout arranges n panels in width x height. It uses as few
+// columns as will fit the panels at full size, since wider panels give the
+// sparklines more history. If nothing fits, panels collapse to a single
+// line each, again in as few columns as fit.
+func computeLayout(width, height, n int) layout {
+	n = max(n, 1)
+	widest := min(maxColumns, n, max(1, (width+panelGap)/(minPanelWidth+panelGap)))
+
+	fits := func(cols int, compact bool) bool {
+		return gridHeight(n, cols, compact) <= height
+	}
+	l := layout{cols: widest, compact: true}
+	for _, compact := range []bool{false, true} {
+		found := false
+		for cols := 1; cols <= widest; cols++ {
+			if fits(cols, compact) {
+				l, found = layout{cols: cols, compact: compact}, true
+				break
+			}
+		}
+		if found {
+			break
+		}
+	}
+	l.colWidth = max(1, (width-(l.cols-1)*panelGap)/l.cols)
+	return l
+}
+
+// gridHeight is the number of lines n panels take in cols columns.
+func gridHeight(n, cols int, compact bool) int {
+	rows := (n + cols - 1) / cols
+	if compact {
+		return rows
+	}
+	return rows*(fullPanelHeight+1) - 1
+}
+
+func (l layout) height(n int) int {
+	return gridHeight(n, l.cols, l.compact)
+}
+
+// barWidth is what's left of a panel's first line after the label and
+// value.
+func (l layout) barWidth() int {
+	return max(4, min(maxBarWidth, l.colWidth-20))
+}
+
+// grid places rendered panels left to right, top to bottom, padding each
+// to the column width so columns line up.
+func (l layout) grid(panels []string) string {
+	cell := lipgloss.NewStyle().Width(l.colWidth)
+	gap := strings.Repeat(" ", panelGap)
+
+	var rows []string
+	for start := 0; start < len(panels); start += l.cols {
+		var cells []string
+		for i := start; i < min(start+l.cols, len(panels)); i++ {
+			if i > start {
+				cells = append(cells, gap)
+			}
+			cells = append(cells, cell.Render(panels[i]))
+		}
+		rows = append(rows, lipgloss.JoinHorizontal(lipgloss.Top, cells...))
+	}
+
+	sep := "\n\n"
+	if l.compact {
+		sep = "\n"
+	}
+	return strings.Join(rows, sep)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+func TestComputeLayout(t *testing.T) {
+	tests := []struct {
+		width, height, n int
+		want             layout
+	}{
+		// Room for every panel in one wide column.
+		{120, 60, 4, layout{cols: 1, colWidth: 120}},
+		// Not tall enough for one column, so spread across two.
+		{120, 20, 9, layout{cols: 2, colWidth: 59}},
+		{200, 12, 9, layout{cols: 3, colWidth: 65}},
+		// Too narrow for more than one column, and too short for full
+		// panels.
+		{50, 20, 9, layout{cols: 1, colWidth: 50, compact: true}},
+		// Too short for even three columns of full panels.
+		{200, 6, 9, layout{cols: 2, colWidth: 99, compact: true}},
+		// Never more columns than panels.
+		{200, 1, 2, layout{cols: 2, colWidth: 99, compact: true}},
+	}
+	for _, tt := range tests {
+		if got := computeLayout(tt.width, tt.height, tt.n); got != tt.want {
+			t.Errorf("computeLayout(%d, %d, %d) = %+v; want %+v", tt.width, tt.height, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestViewFitsTerminal(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	for _, size := range [][2]int{{40, 60}, {60, 20}, {80, 24}, {120, 40}, {200, 30}} {
+		t.Run(fmt.Sprintf("%dx%d", size[0], size[1]), func(t *testing.T) {
+			m := initialModel(options{})
+			m.width, m.height = size[0], size[1]
+			for i := 0; i < 20; i++ {
+				m, _ = m.applySample(sample{
+					Time: start.Add(time.Duration(i) * time.Second),
+					Metrics: map[string]float64{
+						"CPU": float64(5 * i), "Load": 1.5, "Memory": 40, "Swap": 3,
+						"PSI cpu": 1, "PSI mem": 0, "PSI io": 2, "Network": 12, "Disk": 70,
+					},
+				})
+			}
+
+			view := m.View()
+			if h := lipgloss.Height(view); h > m.height {
+				t.Errorf("view is %d lines; want at most %d", h, m.height)
+			}
+			for _, line := range strings.Split(view, "\n") {
+				if w := lipgloss.Width(line); w > m.width {
+					t.Errorf("line is %d wide; want at most %d: %q", w, m.width, line)
+				}
+			}
+		})
+	}
+}
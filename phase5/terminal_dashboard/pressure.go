@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// errUnavailable marks a source that doesn't exist on this system, such as
+// PSI on a kernel without CONFIG_PSI. Its metrics are hidden, not shown as
+// failing.
+var errUnavailable = errors.New("not available on this system")
+
+// psiLine is one line of a /proc/pressure file: the share of wall time
+// some (or all, for "full") tasks were stalled on the resource.
+type psiLine struct {
+	Avg10  float64
+	Avg60  float64
+	Avg300 float64
+	Total  time.Duration
+}
+
+type pressure struct {
+	Some psiLine
+	Full *psiLine // absent for cpu on older kernels
+}
+
+// pressureResources maps the metric name for each resource to its file.
+var pressureResources = []struct {
+	metric string
+	file   string
+}{
+	{"PSI cpu", "cpu"},
+	{"PSI mem", "memory"},
+	{"PSI io", "io"},
+}
+
+func parsePressure(r io.Reader) (pressure, error) {
+	var p pressure
+	found := false
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		var line psiLine
+		for _, f := range fields[1:] {
+			key, val, ok := strings.Cut(f, "=")
+			if !ok {
+				return p, fmt.Errorf("malformed field %q", f)
+			}
+			switch key {
+			case "avg10", "avg60", "avg300":
+				v, err := strconv.ParseFloat(val, 64)
+				if err != nil {
+					return p, fmt.Errorf("%s: %v", key, err)
+				}
+				switch key {
+				case "avg10":
+					line.Avg10 = v
+				case "avg60":
+					line.Avg60 = v
+				default:
+					line.Avg300 = v
+				}
+			case "total":
+				us, err := strconv.ParseUint(val, 10, 64)
+				if err != nil {
+					return p, fmt.Errorf("total: %v", err)
+				}
+				line.Total = time.Duration(us) * time.Microsecond
+			}
+		}
+		switch fields[0] {
+		case "some":
+			p.Some = line
+			found = true
+		case "full":
+			p.Full = &line
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return p, err
+	}
+	if !found {
+		return p, errors.New("no \"some\" line")
+	}
+	return p, nil
+}
+
+// pressureSource reads PSI from procRoot/pressure. If the directory is
+// missing, or reading it is refused (PSI compiled in but disabled with
+// psi=0), the PSI rows are hidden.
+func pressureSource(procRoot string) source {
+	return source{name: "Pressure", timeout: time.Second, read: func(context.Context, time.Time) (func(*sample), error) {
+		results := map[string]pressure{}
+		for _, res := range pressureResources {
+			data, err := os.ReadFile(filepath.Join(procRoot, "pressure", res.file))
+			if errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrPermission) || errors.Is(err, syscall.EOPNOTSUPP) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			p, err := parsePressure(bytes.NewReader(data))
+			if err != nil {
+				return nil, fmt.Errorf("pressure/%s: %v", res.file, err)
+			}
+			results[res.metric] = p
+		}
+		if len(results) == 0 {
+			return nil, errUnavailable
+		}
+		return func(s *sample) {
+			s.Pressure = results
+			for metric, p := range results {
+				s.Metrics[metric] = p.Some.Avg10
+			}
+		}, nil
+	}}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParsePressure(t *testing.T) {
+	input := "some avg10=1.50 avg60=2.25 avg300=0.10 total=123456\n" +
+		"full avg10=0.50 avg60=0.00 avg300=0.00 total=1000000\n"
+
+	p, err := parsePressure(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Some.Avg10 != 1.5 || p.Some.Avg60 != 2.25 || p.Some.Avg300 != 0.1 {
+		t.Errorf("some = %+v", p.Some)
+	}
+	if p.Some.Total != 123456*time.Microsecond {
+		t.Errorf("some total = %v", p.Some.Total)
+	}
+	if p.Full == nil || p.Full.Total != time.Second {
+		t.Errorf("full = %+v", p.Full)
+	}
+
+	if _, err := parsePressure(strings.NewReader("some avg10=x\n")); err == nil {
+		t.Error("malformed value should be an error")
+	}
+}
+
+func TestPressureSourceFixture(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "pressure")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// An older kernel reports only "some" for cpu; memory is missing here
+	// to check partial availability.
+	files := map[string]string{
+		"cpu": "some avg10=3.00 avg60=0.00 avg300=0.00 total=0\n",
+		"io":  "some avg10=7.50 avg60=0.00 avg300=0.00 total=0\nfull avg10=2.00 avg60=0.00 avg300=0.00 total=0\n",
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fill, err := pressureSource(root).read(context.Background(), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := sample{Metrics: map[string]float64{}}
+	fill(&s)
+
+	if s.Metrics["PSI cpu"] != 3 || s.Metrics["PSI io"] != 7.5 {
+		t.Errorf("metrics = %v", s.Metrics)
+	}
+	if _, ok := s.Metrics["PSI mem"]; ok {
+		t.Error("missing memory pressure file should leave its metric out")
+	}
+}
+
+func TestPressureSourceUnavailable(t *testing.T) {
+	_, err := pressureSource(t.TempDir()).read(context.Background(), time.Now())
+	if !errors.Is(err, errUnavailable) {
+		t.Fatalf("err = %v; want errUnavailable", err)
+	}
+
+	s := assemble(time.Now(), []reading{{source: "Pressure", err: err}})
+	if len(s.Errors) != 0 {
+		t.Errorf("unavailable source should not be reported as an error: %v", s.Errors)
+	}
+}
+
+func TestPressureReadFailureShowsStale(t *testing.T) {
+	root := t.TempDir()
+	file := filepath.Join(root, "pressure", "cpu")
+	if err := os.Mkdir(filepath.Dir(file), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, []byte("some avg10=3.00 avg60=0.00 avg300=0.00 total=0\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	src := pressureSource(root)
+	start := time.Unix(1700000000, 0)
+
+	m := initialModel(options{})
+	m.width, m.height = 120, 40
+	fill, err := src.read(context.Background(), start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := sample{Time: start, Metrics: map[string]float64{}, Processes: []procInfo{{PID: 1, Name: "init"}}}
+	fill(&s)
+	m, _ = m.applySample(s)
+
+	if err := os.WriteFile(file, []byte("garbage\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = src.read(context.Background(), start.Add(5*time.Second))
+	if err == nil {
+		t.Fatal("reading a malformed pressure file should fail")
+	}
+	m, _ = m.applySample(sample{
+		Time:    start.Add(5 * time.Second),
+		Metrics: map[string]float64{},
+		Errors:  map[string]error{"Pressure": err, processesPanel: errors.New("permission denied")},
+	})
+
+	if got := m.healthIndicator("PSI cpu"); !strings.Contains(got, "⚠ error") {
+		t.Errorf("PSI cpu indicator = %q; want an error", got)
+	}
+	if m.metrics["PSI cpu"] != 3 || m.last.Pressure["PSI cpu"].Some.Avg10 != 3 {
+		t.Error("the last pressure reading should stay on screen")
+	}
+	if len(m.last.Processes) != 1 {
+		t.Error("the last process list should stay on screen")
+	}
+	if got := m.processPanel(computeLayout(120, 30, 4)); !strings.Contains(got, "⚠ error") {
+		t.Errorf("Processes panel doesn't show the error:\n%s", got)
+	}
+	var logged []string
+	for _, e := range m.events.entries {
+		logged = append(logged, e.text)
+	}
+	if want := "Pressure stats read error"; !strings.Contains(strings.Join(logged, "\n"), want) {
+		t.Errorf("events = %q; want %q", logged, want)
+	}
+
+	m, _ = m.applySample(sample{Time: start.Add(6 * time.Second), Metrics: map[string]float64{"PSI cpu": 1}, Processes: []procInfo{}})
+	if m.healthIndicator("PSI cpu") != "" || m.healthIndicator(processesPanel) != "" {
+		t.Error("a good read should clear the indicators")
+	}
+}
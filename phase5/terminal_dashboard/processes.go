@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/process"
+)
+
+// procInfo is one process as shown in the Processes panel.
+type procInfo struct {
+	PID  int32
+	Name string
+	CPU  float64 // percent of one core, like top
+	RSS  uint64
+}
+
+// procReader is the "Processes" source. gopsutil works out a process's CPU
+// percentage from the times seen on the previous call, so the handles are
+// kept between reads; a process's first reading is 0.
+type procReader struct {
+	mu    sync.Mutex
+	procs map[int32]*process.Process
+}
+
+func newProcReader() *procReader {
+	return &procReader{procs: map[int32]*process.Process{}}
+}
+
+func (r *procReader) source() source {
+	return source{name: "Processes", timeout: 3 * time.Second, read: r.read}
+}
+
+func (r *procReader) read(ctx context.Context, _ time.Time) (func(*sample), error) {
+	pids, err := process.PidsWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	seen := make(map[int32]*process.Process, len(pids))
+	procs := make([]procInfo, 0, len(pids))
+	for _, pid := range pids {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		p := r.procs[pid]
+		if p == nil {
+			p = &process.Process{Pid: pid}
+		}
+		// Processes exiting mid-read, or ones we may not inspect, are
+		// skipped rather than failing the whole read.
+		name, err := p.NameWithContext(ctx)
+		if err != nil {
+			continue
+		}
+		cpu, err := p.PercentWithContext(ctx, 0)
+		if err != nil {
+			continue
+		}
+		info := procInfo{PID: pid, Name: name, CPU: cpu}
+		if m, err := p.MemoryInfoWithContext(ctx); err == nil {
+			info.RSS = m.RSS
+		}
+		seen[pid] = p
+		procs = append(procs, info)
+	}
+	// Forget exited processes so a reused pid starts afresh.
+	r.procs = seen
+
+	return func(s *sample) {
+		s.Processes = procs
+	}, nil
+}
+
+// procSorts are the orders the Processes panel cycles through with "o".
+var procSorts = []struct {
+	name string
+	less func(a, b procInfo) bool
+}{
+	{"cpu", func(a, b procInfo) bool { return a.CPU > b.CPU }},
+	{"mem", func(a, b procInfo) bool { return a.RSS > b.RSS }},
+	{"pid", func(a, b procInfo) bool { return a.PID < b.PID }},
+	{"name", func(a, b procInfo) bool { return strings.ToLower(a.Name) < strings.ToLower(b.Name) }},
+}
+
+// sortProcs returns a sorted copy; the sample's slice is shared with
+// whatever else holds the sample.
+func sortProcs(procs []procInfo, by int, reverse bool) []procInfo {
+	out := append([]procInfo(nil), procs...)
+	less := procSorts[by].less
+	sort.SliceStable(out, func(i, j int) bool {
+		if reverse {
+			return less(out[j], out[i])
+		}
+		return less(out[i], out[j])
+	})
+	return out
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// recorder appends samples to a file, one JSON object per line, so a
+// recording can be written tick by tick and still be read back after a
+// crash. Each line is the whole sample, read errors included, so replay
+// shows what the live view did.
+type recorder struct {
+	f   *os.File
+	enc *json.Encoder
+}
+
+func openRecorder(path string) (*recorder, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &recorder{f: f, enc: json.NewEncoder(f)}, nil
+}
+
+func (r *recorder) write(s sample) error {
+	return r.enc.Encode(s)
+}
+
+func (r *recorder) Close() error {
+	return r.f.Close()
+}
+
+func loadRecording(path string) ([]sample, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var samples []sample
+	var torn error
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		if torn != nil {
+			return nil, torn
+		}
+		var s sample
+		if err := json.Unmarshal(scanner.Bytes(), &s); err != nil {
+			// A torn final line is expected if the recorder was killed
+			// mid-write, so only fail if more lines follow it.
+			torn = fmt.Errorf("%s:%d: %v", path, n, err)
+			continue
+		}
+		samples = append(samples, s)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(samples) == 0 {
+		return nil, fmt.Errorf("%s: recording is empty", path)
+	}
+
+	return samples, nil
+}
+
+var replaySpeeds = []float64{0.25, 0.5, 1, 2, 4, 8, 16, 32}
+
+// player feeds a recording back through Update at the recorded pace,
+// scaled by speed.
+type player struct {
+	samples []sample
+	pos     int
+	playing bool
+	speed   int
+	gen     int
+}
+
+func newPlayer(samples []sample) *player {
+	return &player{samples: samples, playing: true, speed: 2}
+}
+
+type replayTickMsg struct{ gen int }
+
+func (p *player) next() tea.Cmd {
+	if !p.playing || p.pos >= len(p.samples) {
+		return nil
+	}
+
+	delay := time.Duration(0)
+	if p.pos > 0 {
+		delay = p.samples[p.pos].Time.Sub(p.samples[p.pos-1].Time)
+	}
+	delay = time.Duration(float64(delay) / replaySpeeds[p.speed])
+
+	gen := p.gen
+	return tea.Tick(delay, func(time.Time) tea.Msg {
+		return replayTickMsg{gen: gen}
+	})
+}
+
+func (p *player) togglePlay() tea.Cmd {
+	p.gen++
+	p.playing = !p.playing
+	if p.pos >= len(p.samples) {
+		p.pos = 0
+	}
+	return p.next()
+}
+
+func (p *player) faster() {
+	if p.speed < len(replaySpeeds)-1 {
+		p.speed++
+	}
+}
+
+func (p *player) slower() {
+	if p.speed > 0 {
+		p.speed--
+	}
+}
+
+// seek moves the playback position by d of recorded time and restarts the
+// tick chain so a pending tick from before the seek is ignored.
+func (p *player) seek(d time.Duration) tea.Cmd {
+	if len(p.samples) == 0 {
+		return nil
+	}
+	cur := p.pos
+	if cur >= len(p.samples) {
+		cur = len(p.samples) - 1
+	}
+	target := p.samples[cur].Time.Add(d)
+
+	pos := cur
+	for pos > 0 && p.samples[pos].Time.After(target) {
+		pos--
+	}
+	for pos < len(p.samples)-1 && p.samples[pos].Time.Before(target) {
+		pos++
+	}
+	p.pos = pos
+	p.gen++
+	return p.next()
+}
+
+func (p *player) status() string {
+	state := "⏸"
+	if p.playing {
+		state = "▶"
+	}
+	if p.pos >= len(p.samples) {
+		state = "■"
+	}
+	first, last := p.samples[0].Time, p.samples[len(p.samples)-1].Time
+	cur := last
+	if p.pos > 0 && p.pos <= len(p.samples) {
+		cur = p.samples[p.pos-1].Time
+	} else if p.pos == 0 {
+		cur = first
+	}
+	return fmt.Sprintf("Replay %s %gx  %s / %s", state, replaySpeeds[p.speed],
+		cur.Sub(first).Round(time.Second), last.Sub(first).Round(time.Second))
+}
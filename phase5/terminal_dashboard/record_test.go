@@ -0,0 +1,104 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRecordingRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.rec")
+	start := time.UnixMilli(1_700_000_000_000)
+
+	for i := 0; i < 2; i++ {
+		// Reopen to check that recordings append rather than truncate.
+		r, err := openRecorder(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for j := 0; j < 3; j++ {
+			n := i*3 + j
+			err := r.write(sample{
+				Time:    start.Add(time.Duration(n) * time.Second),
+				Metrics: map[string]float64{"CPU": float64(n), "Disk": 50},
+				Errors:  map[string]error{"Network": errNoInterfaces},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		r.Close()
+	}
+
+	samples, err := loadRecording(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 6 {
+		t.Fatalf("len(samples) = %d; want 6", len(samples))
+	}
+	for i, s := range samples {
+		if !s.Time.Equal(start.Add(time.Duration(i) * time.Second)) {
+			t.Errorf("samples[%d].Time = %v", i, s.Time)
+		}
+		if s.Metrics["CPU"] != float64(i) {
+			t.Errorf("samples[%d] CPU = %v; want %d", i, s.Metrics["CPU"], i)
+		}
+		if err := s.Errors["Network"]; err == nil || err.Error() != errNoInterfaces.Error() {
+			t.Errorf("samples[%d] Network error = %v; want %v", i, err, errNoInterfaces)
+		}
+	}
+}
+
+func TestLoadRecordingTornLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "torn.rec")
+	data := `{"Time":"2023-11-14T22:13:20Z","Metrics":{"CPU":1}}` + "\n" +
+		`{"Time":"2023-11-14T22:13:21Z","Metrics":{"CPU":2}}` + "\n" + `{"Time":"2023-11`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	samples, err := loadRecording(path)
+	if err != nil {
+		t.Fatalf("torn final line should be ignored, got %v", err)
+	}
+	if len(samples) != 2 {
+		t.Errorf("len(samples) = %d; want 2", len(samples))
+	}
+
+	data = `{"Time":"2023-11-14T22:13:20Z","Metrics":{"CPU":1}}` + "\n" + `garbage` + "\n" +
+		`{"Time":"2023-11-14T22:13:22Z","Metrics":{"CPU":3}}` + "\n"
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadRecording(path); err == nil {
+		t.Error("corrupt line in the middle should be an error")
+	}
+}
+
+func TestPlayerSeek(t *testing.T) {
+	start := time.Unix(0, 0)
+	samples := make([]sample, 100)
+	for i := range samples {
+		samples[i] = sample{Time: start.Add(time.Duration(i) * time.Second)}
+	}
+
+	p := newPlayer(samples)
+	p.seek(30 * time.Second)
+	if p.pos != 30 {
+		t.Errorf("pos after +30s = %d; want 30", p.pos)
+	}
+	p.seek(-10 * time.Second)
+	if p.pos != 20 {
+		t.Errorf("pos after -10s = %d; want 20", p.pos)
+	}
+	p.seek(time.Hour)
+	if p.pos != 99 {
+		t.Errorf("pos after seeking past the end = %d; want 99", p.pos)
+	}
+	p.seek(-time.Hour)
+	if p.pos != 0 {
+		t.Errorf("pos after seeking before the start = %d; want 0", p.pos)
+	}
+}
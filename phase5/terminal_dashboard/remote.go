@@ -0,0 +1,354 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const samplePath = "/v1/sample"
+
+// wireSample is what an agent serves: the whole sample, panel data
+// included, so a drilled-in host shows the same dashboard it would show
+// locally.
+type wireSample struct {
+	Host   string `json:"host"`
+	Sample sample `json:"sample"`
+}
+
+// agentHandler serves the exporter's latest sample as JSON for viewers.
+type agentHandler struct {
+	exp  *exporter
+	host string
+}
+
+func (h agentHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.exp.mu.RLock()
+	s := h.exp.latest
+	h.exp.mu.RUnlock()
+
+	if s.Time.IsZero() {
+		http.Error(w, "no sample collected yet", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(wireSample{Host: h.host, Sample: s})
+}
+
+func agentHostname() string {
+	if h, err := os.Hostname(); err == nil {
+		return h
+	}
+	return "unknown"
+}
+
+var agentClient = &http.Client{Timeout: 2 * time.Second}
+
+func fetchSample(addr string) (string, sample, error) {
+	url := addr
+	if !strings.Contains(url, "://") {
+		url = "http://" + url
+	}
+	resp, err := agentClient.Get(strings.TrimSuffix(url, "/") + samplePath)
+	if err != nil {
+		return "", sample{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", sample{}, fmt.Errorf("%s: %s", addr, resp.Status)
+	}
+
+	var ws wireSample
+	if err := json.NewDecoder(resp.Body).Decode(&ws); err != nil {
+		return "", sample{}, fmt.Errorf("%s: %v", addr, err)
+	}
+	return ws.Host, ws.Sample, nil
+}
+
+type remoteHost struct {
+	addr string
+	name string
+	dash model
+	// lastSeen is the time of the newest sample, by the agent's clock, so
+	// an agent that keeps answering with an old sample goes stale.
+	lastSeen time.Time
+	polling  bool // a request to the agent is outstanding
+	err      error
+}
+
+type remoteSampleMsg struct {
+	idx    int
+	host   string
+	sample sample
+	err    error
+}
+
+type remoteTickMsg time.Time
+
+// viewer polls several agents and shows them as a grid of hosts. Selecting
+// a host drills into the regular dashboard fed by that agent's samples.
+type viewer struct {
+	interval time.Duration
+	hosts    []*remoteHost
+	selected int
+	drilled  bool
+	width    int
+	height   int
+	config   *configSource
+}
+
+func newViewer(addrs []string, opts options) viewer {
+	v := viewer{interval: newSampler(opts.interval).interval, config: opts.config}
+	for _, addr := range addrs {
+		dash := initialModel(opts)
+		dash.collector = nil
+		dash.host = addr
+		v.hosts = append(v.hosts, &remoteHost{addr: addr, name: addr, dash: dash})
+	}
+	return v
+}
+
+// poll asks every agent for its latest sample, skipping agents that
+// haven't answered the previous request yet.
+func (v viewer) poll() tea.Cmd {
+	var cmds []tea.Cmd
+	for i, h := range v.hosts {
+		if h.polling {
+			continue
+		}
+		h.polling = true
+		cmds = append(cmds, func() tea.Msg {
+			name, s, err := fetchSample(h.addr)
+			return remoteSampleMsg{idx: i, host: name, sample: s, err: err}
+		})
+	}
+	return tea.Batch(cmds...)
+}
+
+func (v viewer) Init() tea.Cmd {
+	var watch tea.Cmd
+	if v.config != nil {
+		watch = v.config.check()
+	}
+	return tea.Batch(v.poll(), watch, tea.Tick(v.interval, func(t time.Time) tea.Msg {
+		return remoteTickMsg(t)
+	}))
+}
+
+func (v viewer) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		v.width = msg.Width
+		v.height = msg.Height
+		// Leave a line under the drilled-in dashboard for the back hint.
+		msg.Height--
+		for _, h := range v.hosts {
+			dash, _ := h.dash.Update(msg)
+			h.dash = dash.(model)
+		}
+
+	case tea.KeyMsg:
+		key := msg.String()
+		if key == "ctrl+c" || key == "q" || key == "Q" {
+			return v, tea.Quit
+		}
+		if v.drilled {
+			if key == "esc" || key == "backspace" {
+				v.drilled = false
+				return v, nil
+			}
+			h := v.hosts[v.selected]
+			dash, cmd := h.dash.Update(msg)
+			h.dash = dash.(model)
+			return v, cmd
+		}
+
+		cols := v.columns()
+		switch key {
+		case "right", "l", "tab":
+			v.selected = (v.selected + 1) % len(v.hosts)
+		case "left", "h", "shift+tab":
+			v.selected = (v.selected + len(v.hosts) - 1) % len(v.hosts)
+		case "down", "j":
+			if v.selected+cols < len(v.hosts) {
+				v.selected += cols
+			}
+		case "up", "k":
+			if v.selected-cols >= 0 {
+				v.selected -= cols
+			}
+		case "enter":
+			v.drilled = true
+		}
+
+	case tea.MouseMsg:
+		if v.drilled {
+			h := v.hosts[v.selected]
+			dash, cmd := h.dash.Update(msg)
+			h.dash = dash.(model)
+			return v, cmd
+		}
+
+	case remoteTickMsg:
+		return v, tea.Batch(v.poll(), tea.Tick(v.interval, func(t time.Time) tea.Msg {
+			return remoteTickMsg(t)
+		}))
+
+	case remoteSampleMsg:
+		h := v.hosts[msg.idx]
+		h.polling = false
+		h.err = msg.err
+		if msg.err != nil {
+			return v, nil
+		}
+		if msg.host != "" {
+			h.name = msg.host
+			h.dash.host = msg.host
+		}
+		// An agent whose collection is stuck serves the same sample
+		// again; applying it twice would duplicate history and alerts.
+		if !msg.sample.Time.After(h.lastSeen) {
+			return v, nil
+		}
+		h.lastSeen = msg.sample.Time
+		var cmd tea.Cmd
+		h.dash, cmd = h.dash.applySample(msg.sample)
+		return v, cmd
+
+	case configCheckMsg:
+		return v, v.config.check()
+
+	case configMsg:
+		// The viewer owns the watch, so each host's dashboard gets the
+		// reload without polling the file itself.
+		for _, h := range v.hosts {
+			if msg.err != nil {
+				h.dash.events.add(time.Now(), levelWarn, reloadFailed(msg.err))
+				continue
+			}
+			h.dash, _ = h.dash.applyConfig(msg.cfg)
+			h.dash.events.add(time.Now(), levelInfo, "config reloaded from "+v.config.path)
+		}
+		return v, v.config.check()
+
+	case hookErrMsg:
+		h := v.hosts[v.selected]
+		h.dash.events.add(time.Now(), levelWarn, msg.err.Error())
+
+	case snapshotMsg:
+		// Snapshots are taken from a drilled-in dashboard, so the result
+		// goes back to the selected host's status line.
+		h := v.hosts[v.selected]
+		dash, _ := h.dash.Update(msg)
+		h.dash = dash.(model)
+	}
+
+	return v, nil
+}
+
+const hostCardWidth = 34
+
+func (v viewer) columns() int {
+	return max(1, v.width/(hostCardWidth+2))
+}
+
+func (v viewer) View() string {
+	if v.width == 0 {
+		return "Connecting to agents..."
+	}
+	if v.drilled {
+		return v.hosts[v.selected].dash.View() + "\n" +
+			lipgloss.NewStyle().Foreground(lipgloss.Color("#626262")).Render("esc back to hosts")
+	}
+
+	title := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("#FAFAFA")).
+		Background(lipgloss.Color("#7D56F4")).
+		Padding(0, 1).
+		Width(v.width).
+		Align(lipgloss.Center).
+		Render(fmt.Sprintf("🖥️  Host Overview — %d hosts", len(v.hosts)))
+
+	cols := v.columns()
+	var rows []string
+	for start := 0; start < len(v.hosts); start += cols {
+		var cards []string
+		for i := start; i < min(start+cols, len(v.hosts)); i++ {
+			cards = append(cards, v.hostCard(i))
+		}
+		rows = append(rows, lipgloss.JoinHorizontal(lipgloss.Top, cards...))
+	}
+
+	help := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#626262")).
+		Render("←/→/↑/↓ select  enter drill in  q quit")
+
+	return lipgloss.JoinVertical(
+		lipgloss.Center,
+		title,
+		"",
+		lipgloss.JoinVertical(lipgloss.Left, rows...),
+		"",
+		help,
+	)
+}
+
+func (v viewer) hostCard(i int) string {
+	h := v.hosts[i]
+	const inner = hostCardWidth - 4
+
+	status := lipgloss.NewStyle().Foreground(lipgloss.Color("#04B575")).Render("● online")
+	switch {
+	case h.lastSeen.IsZero() && h.err == nil:
+		status = lipgloss.NewStyle().Foreground(lipgloss.Color("#626262")).Render("○ connecting")
+	case h.err != nil:
+		status = lipgloss.NewStyle().Foreground(lipgloss.Color("#FF5F87")).Render("● unreachable")
+	case time.Since(h.lastSeen) > max(5*time.Second, 3*v.interval):
+		status = lipgloss.NewStyle().Foreground(lipgloss.Color("#FFB86C")).Render("● stale")
+	}
+
+	name := h.name
+	if len(name) > inner {
+		name = name[:inner-1] + "…"
+	}
+	lines := []string{lipgloss.NewStyle().Bold(true).Render(name), status}
+
+	const miniBar = 10
+	for _, metric := range h.dash.visibleMetrics() {
+		value := h.dash.metrics[metric]
+		info := h.dash.info(metric)
+		lo, hi := info.scaleRange([]float64{value})
+		filled := int(math.Round(miniBar * math.Min(math.Max((value-lo)/(hi-lo), 0), 1)))
+		color := lipgloss.Color("#04B575")
+		switch h.dash.alertState(metric) {
+		case alertPending:
+			color = lipgloss.Color("#FFB86C")
+		case alertFiring:
+			color = lipgloss.Color("#FF5F87")
+		}
+		bar := lipgloss.NewStyle().Foreground(color).
+			Render(strings.Repeat("█", filled) + strings.Repeat("░", miniBar-filled))
+		lines = append(lines, fmt.Sprintf("%-7s %s %s", metric, bar, info.format(value)))
+	}
+
+	border := lipgloss.Color("#626262")
+	if i == v.selected {
+		border = lipgloss.Color("#FF5F87")
+	}
+
+	return lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(border).
+		Padding(0, 1).
+		Width(inner + 2).
+		Render(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func startAgent(t *testing.T, host string, cpu float64) *httptest.Server {
+	t.Helper()
+	exp := &exporter{}
+	exp.update(sample{
+		Time:    time.Now(),
+		Metrics: map[string]float64{"CPU": cpu, "Memory": 40, "Disk": 60, "Network": 12},
+		Errors:  map[string]error{"Disk": errors.New("stale NFS handle")},
+	})
+	srv := httptest.NewServer(agentHandler{exp: exp, host: host})
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestFetchSample(t *testing.T) {
+	agent := startAgent(t, "db-1", 42)
+
+	host, s, err := fetchSample(agent.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if host != "db-1" {
+		t.Errorf("host = %q; want db-1", host)
+	}
+	if s.Metrics["CPU"] != 42 {
+		t.Errorf("CPU = %v; want 42", s.Metrics["CPU"])
+	}
+	if s.Errors["Disk"] == nil || s.Errors["Disk"].Error() != "stale NFS handle" {
+		t.Errorf("Disk error = %v; want stale NFS handle", s.Errors["Disk"])
+	}
+}
+
+func TestViewerMultipleAgents(t *testing.T) {
+	a := startAgent(t, "web-1", 10)
+	b := startAgent(t, "web-2", 95)
+	addrs := []string{strings.TrimPrefix(a.URL, "http://"), b.URL, "127.0.0.1:1"}
+
+	v := newViewer(addrs, options{alerts: []alertRule{{Metric: "CPU", Above: true, Threshold: 90}}})
+	var m tea.Model = v
+	m, _ = m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+
+	for i, addr := range addrs {
+		host, s, err := fetchSample(addr)
+		m, _ = m.Update(remoteSampleMsg{idx: i, host: host, sample: s, err: err})
+	}
+
+	v = m.(viewer)
+	if v.hosts[0].name != "web-1" || v.hosts[1].name != "web-2" {
+		t.Errorf("host names = %q, %q", v.hosts[0].name, v.hosts[1].name)
+	}
+	if v.hosts[2].err == nil {
+		t.Error("unreachable agent should record an error")
+	}
+	if v.hosts[1].dash.alertState("CPU") != alertFiring {
+		t.Error("web-2 CPU alert should be firing")
+	}
+
+	view := v.View()
+	for _, want := range []string{"web-1", "web-2", "unreachable"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("overview missing %q", want)
+		}
+	}
+
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRight})
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if view := m.View(); !strings.Contains(view, "System Monitor Dashboard — web-2") {
+		t.Errorf("drill-in should show web-2's dashboard:\n%s", view)
+	}
+	m, _ = m.Update(snapshotMsg{base: "/tmp/dashboard-web-2"})
+	if view := m.View(); !strings.Contains(view, "snapshot saved to /tmp/dashboard-web-2") {
+		t.Errorf("drilled-in dashboard should report the snapshot:\n%s", view)
+	}
+
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if m.(viewer).drilled {
+		t.Error("esc should return to the overview")
+	}
+}
+
+func TestViewerSkipsRepeatedSamples(t *testing.T) {
+	v := newViewer([]string{"db-1:9100"}, options{})
+	var m tea.Model = v
+	s := sample{Time: time.Now(), Metrics: map[string]float64{"CPU": 42}}
+	for i := 0; i < 3; i++ {
+		m, _ = m.Update(remoteSampleMsg{idx: 0, sample: s})
+	}
+	h := m.(viewer).hosts[0]
+	points := 0
+	h.dash.history["CPU"].tiers[0].each(func(b bucket) { points += b.n })
+	if points != 1 {
+		t.Errorf("CPU history has %d points; a repeated sample should be applied once", points)
+	}
+	if !h.lastSeen.Equal(s.Time) {
+		t.Errorf("lastSeen = %v; want the sample's time %v", h.lastSeen, s.Time)
+	}
+}
+
+func TestViewerPollsOneRequestPerHost(t *testing.T) {
+	v := newViewer([]string{"127.0.0.1:1"}, options{})
+	if v.poll() == nil {
+		t.Fatal("first poll should request a sample")
+	}
+	if v.poll() != nil {
+		t.Error("poll should wait for the outstanding request")
+	}
+	var m tea.Model = v
+	m, _ = m.Update(remoteSampleMsg{idx: 0, err: errors.New("connection refused")})
+	if m.(viewer).poll() == nil {
+		t.Error("poll should request again once the agent answered")
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const (
+	defaultInterval = time.Second
+	minInterval     = 100 * time.Millisecond
+	maxInterval     = time.Minute
+)
+
+// tickMsg asks the dashboard to collect a sample. gen identifies the tick
+// chain that produced it, so pausing or changing the interval can start a
+// new chain and let the stale one die out instead of doubling up.
+type tickMsg struct {
+	time time.Time
+	gen  int
+}
+
+func scheduleTick(d time.Duration, gen int) tea.Cmd {
+	return tea.Tick(d, func(t time.Time) tea.Msg {
+		return tickMsg{time: t, gen: gen}
+	})
+}
+
+// nextDelay backs off when a collection took longer than the interval, so
+// a slow read never has the next tick queued up behind it. Once reads are
+// fast again the configured interval is used.
+func nextDelay(interval, took time.Duration) time.Duration {
+	if took <= interval {
+		return interval
+	}
+	return min(maxInterval, (2 * took).Round(minInterval))
+}
+
+func clampInterval(d time.Duration) time.Duration {
+	return max(minInterval, min(maxInterval, d))
+}
+
+// sampler holds the dashboard's sampling schedule.
+type sampler struct {
+	interval time.Duration
+	delay    time.Duration
+	paused   bool
+	gen      int
+}
+
+func newSampler(interval time.Duration) sampler {
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+	interval = clampInterval(interval)
+	return sampler{interval: interval, delay: interval}
+}
+
+// restart begins a new tick chain, e.g. after resuming or retuning.
+func (s *sampler) restart() tea.Cmd {
+	s.gen++
+	if s.paused {
+		return nil
+	}
+	return scheduleTick(s.delay, s.gen)
+}
+
+func (s *sampler) setInterval(d time.Duration) tea.Cmd {
+	s.interval = clampInterval(d)
+	s.delay = s.interval
+	return s.restart()
+}
+
+func (s *sampler) togglePause() tea.Cmd {
+	s.paused = !s.paused
+	return s.restart()
+}
+
+func (s sampler) status() string {
+	switch {
+	case s.paused:
+		return "⏸ paused"
+	case s.delay > s.interval:
+		return fmt.Sprintf("every %v (backed off to %v)", s.interval, s.delay)
+	}
+	return fmt.Sprintf("every %v", s.interval)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextDelay(t *testing.T) {
+	tests := []struct {
+		interval, took, want time.Duration
+	}{
+		{time.Second, 10 * time.Millisecond, time.Second},
+		{time.Second, time.Second, time.Second},
+		{time.Second, 1500 * time.Millisecond, 3 * time.Second},
+		{500 * time.Millisecond, 730 * time.Millisecond, 1500 * time.Millisecond},
+		{time.Second, 5 * time.Minute, maxInterval},
+	}
+
+	for _, tt := range tests {
+		if got := nextDelay(tt.interval, tt.took); got != tt.want {
+			t.Errorf("nextDelay(%v, %v) = %v; want %v", tt.interval, tt.took, got, tt.want)
+		}
+	}
+}
+
+func TestSamplerIgnoresStaleTicks(t *testing.T) {
+	m := initialModel(options{interval: time.Second})
+	gen := m.sampler.gen
+
+	m.sampler.togglePause()
+	m.sampler.togglePause()
+
+	next, cmd := m.Update(tickMsg{time: time.Now(), gen: gen})
+	if cmd != nil {
+		t.Error("a tick from before the pause should not reschedule")
+	}
+	if !next.(model).now.IsZero() {
+		t.Error("a tick from before the pause should not collect")
+	}
+}
+
+func TestSamplerInterval(t *testing.T) {
+	s := newSampler(0)
+	if s.interval != defaultInterval {
+		t.Errorf("default interval = %v; want %v", s.interval, defaultInterval)
+	}
+	for i := 0; i < 10; i++ {
+		s.setInterval(s.interval / 2)
+	}
+	if s.interval != minInterval {
+		t.Errorf("interval = %v; want clamped to %v", s.interval, minInterval)
+	}
+}
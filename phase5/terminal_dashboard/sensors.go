@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+const sensorsPanel = "Sensors"
+
+// sensorStat is what the hardware sensors in sysfs report.
+type sensorStat struct {
+	Temps     []tempReading
+	Fans      []fanReading
+	Batteries []batteryStat
+}
+
+type tempReading struct {
+	Chip    string // hwmon driver name, or "thermal" for thermal zones
+	Label   string
+	Celsius float64
+	// High and Crit are the chip's trip points, zero when not reported.
+	High float64
+	Crit float64
+}
+
+type fanReading struct {
+	Chip  string
+	Label string
+	RPM   float64
+}
+
+type batteryStat struct {
+	Name     string
+	Capacity float64 // percent
+	Status   string  // Charging, Discharging, Full, ...
+	Watts    float64 // charge or discharge rate, zero when not reported
+}
+
+// hottest returns the temperature closest to its critical point, or the
+// highest one when no sensor has trip points.
+func (st *sensorStat) hottest() (tempReading, bool) {
+	var best tempReading
+	found := false
+	for _, t := range st.Temps {
+		if !found || t.Celsius-t.limit() > best.Celsius-best.limit() {
+			best, found = t, true
+		}
+	}
+	return best, found
+}
+
+// limit is the temperature at which a sensor is shown as critical.
+func (t tempReading) limit() float64 {
+	if t.Crit > 0 {
+		return t.Crit
+	}
+	return 90
+}
+
+func (t tempReading) color() lipgloss.Color {
+	high := t.High
+	if high <= 0 {
+		high = t.limit() - 15
+	}
+	switch {
+	case t.Celsius >= t.limit():
+		return lipgloss.Color("#FF5F87")
+	case t.Celsius >= high:
+		return lipgloss.Color("#FFB86C")
+	}
+	return lipgloss.Color("#04B575")
+}
+
+func (t tempReading) name() string {
+	if t.Label == "" {
+		return t.Chip
+	}
+	return t.Chip + " " + t.Label
+}
+
+// sensorsSource reads hwmon chips, thermal zones and batteries under
+// sysRoot/class. Machines without any, such as most VMs and containers,
+// have the panel hidden.
+func sensorsSource(sysRoot string) source {
+	return source{name: "Sensors", timeout: time.Second, read: func(context.Context, time.Time) (func(*sample), error) {
+		st := readSensors(sysRoot)
+		if len(st.Temps)+len(st.Fans)+len(st.Batteries) == 0 {
+			return nil, errUnavailable
+		}
+		return func(s *sample) {
+			s.Sensors = st
+		}, nil
+	}}
+}
+
+// readSensors skips anything it can't read: sysfs attributes of sleeping
+// or missing devices fail with EIO or ENODATA rather than being absent.
+func readSensors(sysRoot string) *sensorStat {
+	st := &sensorStat{}
+	class := filepath.Join(sysRoot, "class")
+
+	chips := map[string]bool{}
+	hwmons, _ := filepath.Glob(filepath.Join(class, "hwmon", "hwmon*"))
+	for _, dir := range hwmons {
+		chip, err := readSysfsString(filepath.Join(dir, "name"))
+		if err != nil {
+			chip = filepath.Base(dir)
+		}
+		chips[chip] = true
+
+		inputs, _ := filepath.Glob(filepath.Join(dir, "temp*_input"))
+		for _, input := range inputs {
+			prefix := strings.TrimSuffix(input, "_input")
+			v, err := readSysfsFloat(input)
+			if err != nil {
+				continue
+			}
+			t := tempReading{Chip: chip, Label: sensorLabel(prefix), Celsius: v / 1000}
+			if v, err := readSysfsFloat(prefix + "_max"); err == nil {
+				t.High = v / 1000
+			}
+			if v, err := readSysfsFloat(prefix + "_crit"); err == nil {
+				t.Crit = v / 1000
+			}
+			st.Temps = append(st.Temps, t)
+		}
+
+		inputs, _ = filepath.Glob(filepath.Join(dir, "fan*_input"))
+		for _, input := range inputs {
+			prefix := strings.TrimSuffix(input, "_input")
+			if v, err := readSysfsFloat(input); err == nil {
+				st.Fans = append(st.Fans, fanReading{Chip: chip, Label: sensorLabel(prefix), RPM: v})
+			}
+		}
+	}
+
+	// Most thermal zones are also registered as an hwmon chip named after
+	// the zone type; only list the ones that aren't.
+	zones, _ := filepath.Glob(filepath.Join(class, "thermal", "thermal_zone*"))
+	for _, dir := range zones {
+		typ, err := readSysfsString(filepath.Join(dir, "type"))
+		if err != nil || chips[typ] {
+			continue
+		}
+		if v, err := readSysfsFloat(filepath.Join(dir, "temp")); err == nil {
+			st.Temps = append(st.Temps, tempReading{Chip: "thermal", Label: typ, Celsius: v / 1000})
+		}
+	}
+
+	supplies, _ := filepath.Glob(filepath.Join(class, "power_supply", "*"))
+	for _, dir := range supplies {
+		if typ, _ := readSysfsString(filepath.Join(dir, "type")); typ != "Battery" {
+			continue
+		}
+		capacity, err := readSysfsFloat(filepath.Join(dir, "capacity"))
+		if err != nil {
+			continue
+		}
+		b := batteryStat{Name: filepath.Base(dir), Capacity: capacity}
+		b.Status, _ = readSysfsString(filepath.Join(dir, "status"))
+		// power_now is in µW; batteries that only report current and
+		// voltage give µA and µV.
+		if p, err := readSysfsFloat(filepath.Join(dir, "power_now")); err == nil {
+			b.Watts = p / 1e6
+		} else if i, err := readSysfsFloat(filepath.Join(dir, "current_now")); err == nil {
+			if v, err := readSysfsFloat(filepath.Join(dir, "voltage_now")); err == nil {
+				b.Watts = i * v / 1e12
+			}
+		}
+		st.Batteries = append(st.Batteries, b)
+	}
+
+	return st
+}
+
+// sensorLabel is a sensor's label file, e.g. "Package id 0", or its
+// attribute name, e.g. "temp1", when the driver doesn't label it.
+func sensorLabel(prefix string) string {
+	if label, err := readSysfsString(prefix + "_label"); err == nil && label != "" {
+		return label
+	}
+	return filepath.Base(prefix)
+}
+
+func readSysfsString(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+func readSysfsFloat(path string) (float64, error) {
+	s, err := readSysfsString(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+func formatCelsius(v float64) string {
+	return fmt.Sprintf("%5.1f°C", v)
+}
+
+func (b batteryStat) String() string {
+	s := fmt.Sprintf("%3.0f%%", b.Capacity)
+	if b.Status != "" {
+		s += " " + strings.ToLower(b.Status)
+	}
+	if b.Watts > 0 {
+		s += fmt.Sprintf(" %.1f W", b.Watts)
+	}
+	return s
+}
+
+// sensorPanel is the Sensors entry in the grid: the hottest sensor, then
+// the fans and batteries.
+func (m model) sensorPanel(l layout) string {
+	st := m.last.Sensors
+	gray := lipgloss.NewStyle().Foreground(lipgloss.Color("#626262"))
+
+	var line string
+	var extra []string
+	if t, ok := st.hottest(); ok {
+		color := t.color()
+		line = fmt.Sprintf("%s %s %s", m.panelLabel(sensorsPanel, color),
+			lipgloss.NewStyle().Foreground(color).Render(miniBar(t.Celsius, 0, t.limit(), l.barWidth())),
+			formatCelsius(t.Celsius))
+		extra = append(extra, t.name())
+	} else {
+		line = m.panelLabel(sensorsPanel, lipgloss.Color("#04B575"))
+	}
+	if len(st.Fans) > 0 {
+		var fans []string
+		for _, f := range st.Fans {
+			fans = append(fans, fmt.Sprintf("%.0f", f.RPM))
+		}
+		extra = append(extra, "fans "+strings.Join(fans, "/")+" rpm")
+	}
+	for _, b := range st.Batteries {
+		extra = append(extra, "battery "+b.String())
+	}
+
+	if l.compact {
+		if len(st.Temps) == 0 && len(extra) > 0 {
+			line += " " + extra[0]
+		}
+		return line + m.staleMark(sensorsPanel)
+	}
+	lines := []string{line}
+	if len(extra) > 0 {
+		// The hottest sensor's name and the fans share the stats line.
+		stats := extra[0]
+		rest := extra[1:]
+		if len(st.Temps) > 0 && len(st.Fans) > 0 {
+			stats += " · " + extra[1]
+			rest = extra[2:]
+		}
+		lines = append(lines, gray.Render(ansi.Truncate(stats, l.colWidth, "…")))
+		if len(rest) > 0 {
+			lines = append(lines, gray.Render(ansi.Truncate(strings.Join(rest, " · "), l.colWidth, "…")))
+		}
+	}
+	lines = m.withIndicator(sensorsPanel, lines, l)
+	for len(lines) < fullPanelHeight {
+		lines = append(lines, "")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// sensorTable lists every sensor for the detail view, in at most rows
+// lines.
+func (m model) sensorTable(width, rows int) string {
+	st := m.last.Sensors
+	var lines []string
+	for _, t := range st.Temps {
+		limits := ""
+		if t.High > 0 {
+			limits += fmt.Sprintf("  high %s", strings.TrimSpace(formatCelsius(t.High)))
+		}
+		if t.Crit > 0 {
+			limits += fmt.Sprintf("  crit %s", strings.TrimSpace(formatCelsius(t.Crit)))
+		}
+		lines = append(lines, fmt.Sprintf("%-24s %s %s%s",
+			ansi.Truncate(t.name(), 24, "…"),
+			lipgloss.NewStyle().Foreground(t.color()).Render(miniBar(t.Celsius, 0, t.limit(), 10)),
+			formatCelsius(t.Celsius), limits))
+	}
+	for _, f := range st.Fans {
+		lines = append(lines, fmt.Sprintf("%-24s %6.0f rpm", ansi.Truncate(f.Chip+" "+f.Label, 24, "…"), f.RPM))
+	}
+	for _, b := range st.Batteries {
+		lines = append(lines, fmt.Sprintf("%-24s %s %s", b.Name, miniBar(b.Capacity, 0, 100, 10), b))
+	}
+	if len(lines) > rows {
+		lines = append(lines[:rows-1], fmt.Sprintf("… %d more", len(lines)-rows+1))
+	}
+	for i, line := range lines {
+		lines[i] = ansi.Truncate(line, width, "…")
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+func TestSensorsFixture(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"class/hwmon/hwmon0/name":        "coretemp\n",
+		"class/hwmon/hwmon0/temp1_input": "62000\n",
+		"class/hwmon/hwmon0/temp1_label": "Package id 0\n",
+		"class/hwmon/hwmon0/temp1_max":   "84000\n",
+		"class/hwmon/hwmon0/temp1_crit":  "100000\n",
+		"class/hwmon/hwmon0/temp2_input": "48500\n",
+		"class/hwmon/hwmon1/name":        "acpitz\n",
+		"class/hwmon/hwmon1/temp1_input": "27800\n",
+		"class/hwmon/hwmon2/name":        "thinkpad\n",
+		"class/hwmon/hwmon2/fan1_input":  "2100\n",
+		// A sensor that is asleep reads back garbage rather than a number.
+		"class/hwmon/hwmon2/temp1_input": "N/A\n",
+
+		// acpitz is already listed as hwmon1; x86_pkg_temp is not.
+		"class/thermal/thermal_zone0/type": "acpitz\n",
+		"class/thermal/thermal_zone0/temp": "27800\n",
+		"class/thermal/thermal_zone1/type": "x86_pkg_temp\n",
+		"class/thermal/thermal_zone1/temp": "61000\n",
+
+		"class/power_supply/AC/type":          "Mains\n",
+		"class/power_supply/BAT0/type":        "Battery\n",
+		"class/power_supply/BAT0/capacity":    "85\n",
+		"class/power_supply/BAT0/status":      "Discharging\n",
+		"class/power_supply/BAT0/current_now": "1500000\n",
+		"class/power_supply/BAT0/voltage_now": "12000000\n",
+	})
+
+	fill, err := sensorsSource(root).read(context.Background(), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := sample{Metrics: map[string]float64{}}
+	fill(&s)
+	st := s.Sensors
+
+	wantTemps := []tempReading{
+		{Chip: "coretemp", Label: "Package id 0", Celsius: 62, High: 84, Crit: 100},
+		{Chip: "coretemp", Label: "temp2", Celsius: 48.5},
+		{Chip: "acpitz", Label: "temp1", Celsius: 27.8},
+		{Chip: "thermal", Label: "x86_pkg_temp", Celsius: 61},
+	}
+	if len(st.Temps) != len(wantTemps) {
+		t.Fatalf("temps = %+v; want %+v", st.Temps, wantTemps)
+	}
+	for i, want := range wantTemps {
+		if st.Temps[i] != want {
+			t.Errorf("temps[%d] = %+v; want %+v", i, st.Temps[i], want)
+		}
+	}
+	if len(st.Fans) != 1 || st.Fans[0] != (fanReading{Chip: "thinkpad", Label: "fan1", RPM: 2100}) {
+		t.Errorf("fans = %+v; want thinkpad fan1 at 2100 rpm", st.Fans)
+	}
+	if len(st.Batteries) != 1 {
+		t.Fatalf("batteries = %+v; want BAT0 only", st.Batteries)
+	}
+	if b := st.Batteries[0]; b.Name != "BAT0" || b.Capacity != 85 || b.Status != "Discharging" || math.Abs(b.Watts-18) > 1e-9 {
+		t.Errorf("battery = %+v; want BAT0 85%% discharging at 18 W", b)
+	}
+
+	// The package is hotter, but 38°C short of its critical point; the
+	// thermal zone has none, so it's measured against 90°C.
+	if hot, _ := st.hottest(); hot.Label != "x86_pkg_temp" {
+		t.Errorf("hottest = %+v; want x86_pkg_temp", hot)
+	}
+}
+
+func TestSensorsUnavailable(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		"class/power_supply/AC/type":   "Mains\n",
+		"class/hwmon/hwmon0/name":      "nvme\n",
+		"class/thermal/cooling_device": "",
+	})
+	if _, err := sensorsSource(root).read(context.Background(), time.Now()); !errors.Is(err, errUnavailable) {
+		t.Errorf("err = %v; want errUnavailable", err)
+	}
+}
+
+func TestSensorsPanel(t *testing.T) {
+	m := focusModel(120, 60)
+	if strings.Contains(strings.Join(m.panels(), ","), sensorsPanel) {
+		t.Fatal("Sensors panel should be hidden without sensors")
+	}
+
+	m, _ = m.applySample(sample{
+		Time:    m.now.Add(time.Second),
+		Metrics: map[string]float64{"CPU": 20},
+		Sensors: &sensorStat{
+			Temps:     []tempReading{{Chip: "coretemp", Label: "Package id 0", Celsius: 71, Crit: 100}},
+			Fans:      []fanReading{{Chip: "thinkpad", Label: "fan1", RPM: 2100}},
+			Batteries: []batteryStat{{Name: "BAT0", Capacity: 85, Status: "Charging"}},
+		},
+	})
+	view := ansi.Strip(m.View())
+	for _, want := range []string{"Sensors", "71.0°C", "coretemp Package id 0", "2100 rpm", "battery  85% charging"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("view is missing %q:\n%s", want, view)
+		}
+	}
+	m, _ = m.applySample(sample{
+		Time:    m.now.Add(time.Second),
+		Metrics: map[string]float64{"CPU": 20},
+		Errors:  map[string]error{sensorsPanel: errors.New("permission denied")},
+	})
+	view = ansi.Strip(m.View())
+	if !strings.Contains(view, "71.0°C") || !strings.Contains(view, "⚠ error") {
+		t.Errorf("a failed read should keep the last sensors, marked stale:\n%s", view)
+	}
+}
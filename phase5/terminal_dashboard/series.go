@@ -0,0 +1,188 @@
+package main
+
+import (
+	"math"
+	"strconv"
+	"time"
+)
+
+// bucket summarises the samples that fell into one interval.
+type bucket struct {
+	start time.Time
+	min   float64
+	max   float64
+	sum   float64
+	n     int
+}
+
+func (b *bucket) add(v float64) {
+	if b.n == 0 || v < b.min {
+		b.min = v
+	}
+	if b.n == 0 || v > b.max {
+		b.max = v
+	}
+	b.sum += v
+	b.n++
+}
+
+func (b *bucket) merge(o bucket) {
+	if o.n == 0 {
+		return
+	}
+	if b.n == 0 || o.min < b.min {
+		b.min = o.min
+	}
+	if b.n == 0 || o.max > b.max {
+		b.max = o.max
+	}
+	b.sum += o.sum
+	b.n += o.n
+}
+
+func (b bucket) avg() float64 {
+	if b.n == 0 {
+		return math.NaN()
+	}
+	return b.sum / float64(b.n)
+}
+
+// tier is a fixed-size ring of buckets at one resolution. The bucket
+// currently being filled is kept aside until its interval ends.
+type tier struct {
+	res     time.Duration
+	buckets []bucket
+	head    int
+	count   int
+	cur     bucket
+}
+
+func newTier(res time.Duration, span time.Duration) *tier {
+	return &tier{res: res, buckets: make([]bucket, int(span/res))}
+}
+
+func (t *tier) add(at time.Time, v float64) {
+	start := at.Truncate(t.res)
+	if t.cur.n > 0 && !start.Equal(t.cur.start) {
+		t.buckets[t.head] = t.cur
+		t.head = (t.head + 1) % len(t.buckets)
+		if t.count < len(t.buckets) {
+			t.count++
+		}
+		t.cur = bucket{}
+	}
+	if t.cur.n == 0 {
+		t.cur.start = start
+	}
+	t.cur.add(v)
+}
+
+func (t *tier) span() time.Duration {
+	return t.res * time.Duration(len(t.buckets))
+}
+
+// each calls fn for every bucket, oldest first, including the partial one.
+func (t *tier) each(fn func(bucket)) {
+	for i := 0; i < t.count; i++ {
+		fn(t.buckets[(t.head-t.count+i+len(t.buckets))%len(t.buckets)])
+	}
+	if t.cur.n > 0 {
+		fn(t.cur)
+	}
+}
+
+// series stores a metric at several resolutions so long windows stay in
+// bounded memory: raw seconds for 5 minutes, 10s buckets for an hour and
+// 5m buckets for a day.
+type series struct {
+	tiers []*tier
+}
+
+func newSeries() *series {
+	return &series{tiers: []*tier{
+		newTier(time.Second, 5*time.Minute),
+		newTier(10*time.Second, time.Hour),
+		newTier(5*time.Minute, 24*time.Hour),
+	}}
+}
+
+func (s *series) add(at time.Time, v float64) {
+	for _, t := range s.tiers {
+		t.add(at, v)
+	}
+}
+
+// tierFor returns the finest tier that covers the whole window.
+func (s *series) tierFor(w time.Duration) *tier {
+	for _, t := range s.tiers {
+		if t.span() >= w {
+			return t
+		}
+	}
+	return s.tiers[len(s.tiers)-1]
+}
+
+// buckets returns the stored buckets that overlap the last w before now,
+// oldest first, at the resolution window would read them from. A nil
+// series, for a metric with no samples yet, has none.
+func (s *series) buckets(now time.Time, w time.Duration) []bucket {
+	if s == nil {
+		return nil
+	}
+	src := s.tierFor(w)
+	from := now.Truncate(src.res).Add(src.res - w)
+	var out []bucket
+	src.each(func(b bucket) {
+		if b.start.Add(src.res).After(from) {
+			out = append(out, b)
+		}
+	})
+	return out
+}
+
+// window downsamples the last w of data ending at now into points buckets,
+// reading from the finest tier that covers the whole window. Buckets with
+// no data have n == 0, as do all of a nil series' buckets.
+func (s *series) window(now time.Time, w time.Duration, points int) []bucket {
+	if s == nil {
+		return make([]bucket, points)
+	}
+	src := s.tierFor(w)
+
+	// Align the window to the end of the bucket holding now so the newest
+	// bucket lands in the last slot rather than straddling the edge.
+	end := now.Truncate(src.res).Add(src.res)
+	from := end.Add(-w)
+	out := make([]bucket, points)
+	slot := w / time.Duration(points)
+	if slot <= 0 {
+		slot = 1
+	}
+	// A bucket is merged into every slot it overlaps, so when the window
+	// has fewer buckets than points each one stretches over several slots.
+	src.each(func(b bucket) {
+		bEnd := b.start.Add(src.res)
+		if !bEnd.After(from) || !b.start.Before(end) {
+			return
+		}
+		first := max(0, int(b.start.Sub(from)/slot))
+		last := min(points-1, int((bEnd.Sub(from)-1)/slot))
+		for i := first; i <= last; i++ {
+			out[i].merge(b)
+		}
+	})
+
+	return out
+}
+
+var zoomWindows = []time.Duration{30 * time.Second, 5 * time.Minute, time.Hour, 24 * time.Hour}
+
+func windowLabel(w time.Duration) string {
+	switch {
+	case w >= time.Hour && w%time.Hour == 0:
+		return strconv.Itoa(int(w/time.Hour)) + "h"
+	case w >= time.Minute && w%time.Minute == 0:
+		return strconv.Itoa(int(w/time.Minute)) + "m"
+	}
+	return strconv.Itoa(int(w/time.Second)) + "s"
+}
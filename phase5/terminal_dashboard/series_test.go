@@ -0,0 +1,79 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestTierRingWraps(t *testing.T) {
+	tr := newTier(time.Second, 5*time.Second)
+	start := time.Unix(1000, 0)
+	for i := 0; i < 12; i++ {
+		tr.add(start.Add(time.Duration(i)*time.Second), float64(i))
+	}
+
+	var got []float64
+	tr.each(func(b bucket) { got = append(got, b.avg()) })
+
+	// Five completed buckets plus the one still being filled.
+	want := []float64{6, 7, 8, 9, 10, 11}
+	if len(got) != len(want) {
+		t.Fatalf("buckets = %v; want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("buckets = %v; want %v", got, want)
+			break
+		}
+	}
+}
+
+func TestSeriesDownsamples(t *testing.T) {
+	s := newSeries()
+	start := time.Unix(0, 0)
+	now := start
+	// Two hours of one-second samples alternating between 0 and 100.
+	for i := 0; i < 2*3600; i++ {
+		now = start.Add(time.Duration(i) * time.Second)
+		s.add(now, float64((i%2)*100))
+	}
+
+	buckets := s.window(now, time.Hour, 60)
+	for i, b := range buckets {
+		if b.n == 0 {
+			t.Fatalf("bucket %d is empty", i)
+		}
+		if b.min != 0 || b.max != 100 {
+			t.Errorf("bucket %d min/max = %v/%v; want 0/100", i, b.min, b.max)
+		}
+		if math.Abs(b.avg()-50) > 1 {
+			t.Errorf("bucket %d avg = %v; want ~50", i, b.avg())
+		}
+	}
+
+	if n := len(s.tiers[0].buckets) + len(s.tiers[1].buckets) + len(s.tiers[2].buckets); n > 1000 {
+		t.Errorf("series holds %d buckets; want bounded storage", n)
+	}
+}
+
+func TestSeriesWindowStretches(t *testing.T) {
+	s := newSeries()
+	start := time.Unix(0, 0)
+	var now time.Time
+	for i := 0; i < 30; i++ {
+		now = start.Add(time.Duration(i) * time.Second)
+		s.add(now, float64(i))
+	}
+
+	// 30 samples stretched over 90 points should leave no gaps.
+	buckets := s.window(now, 30*time.Second, 90)
+	for i, b := range buckets {
+		if b.n == 0 {
+			t.Errorf("point %d is empty", i)
+		}
+	}
+	if buckets[len(buckets)-1].avg() != 29 {
+		t.Errorf("last point = %v; want 29", buckets[len(buckets)-1].avg())
+	}
+}
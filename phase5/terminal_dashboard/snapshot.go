@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// snapshot is the dashboard state at the moment "s" was pressed. It's
+// captured in Update, since the history it copies keeps changing, and
+// written out in a command.
+type snapshot struct {
+	Host    string                    `json:"host,omitempty"`
+	Time    time.Time                 `json:"time"`
+	Window  string                    `json:"window"`
+	Metrics map[string]snapshotMetric `json:"metrics"`
+
+	window time.Duration
+	order  []string
+	screen string // View() output, with ANSI colors
+}
+
+type snapshotMetric struct {
+	Value   float64         `json:"value"`
+	Unit    string          `json:"unit,omitempty"`
+	Alert   string          `json:"alert,omitempty"`
+	Error   string          `json:"error,omitempty"`
+	History []snapshotPoint `json:"history"`
+
+	info metricInfo // how the SVG shows the values
+}
+
+// snapshotPoint is one history bucket at the resolution the zoom window is
+// drawn from. Gaps in collection show up as gaps between start times.
+type snapshotPoint struct {
+	Start time.Time `json:"start"`
+	Min   float64   `json:"min"`
+	Avg   float64   `json:"avg"`
+	Max   float64   `json:"max"`
+}
+
+type snapshotMsg struct {
+	base string
+	err  error
+}
+
+func (m model) snapshot() snapshot {
+	window := zoomWindows[m.zoom]
+	snap := snapshot{
+		Host:    m.host,
+		Time:    m.now,
+		Window:  windowLabel(window),
+		Metrics: map[string]snapshotMetric{},
+		window:  window,
+		order:   m.visibleMetrics(),
+		screen:  m.View(),
+	}
+	for _, metric := range snap.order {
+		// Values are written in the unit the screen shows them in, so the
+		// info kept for drawing the SVG has nothing left to convert.
+		info := m.info(metric)
+		sm := snapshotMetric{
+			Value:   info.convert(m.metrics[metric]),
+			Unit:    info.unit,
+			History: []snapshotPoint{},
+			info:    metricInfo{unit: info.unit, scale: info.scale},
+		}
+		switch m.alertState(metric) {
+		case alertPending:
+			sm.Alert = "pending"
+		case alertFiring:
+			sm.Alert = "firing"
+		}
+		if h := m.healthOf(metric); h != nil {
+			sm.Error = h.err.Error()
+		}
+		for _, b := range m.history[metric].buckets(m.now, window) {
+			sm.History = append(sm.History, snapshotPoint{
+				Start: b.start, Min: info.convert(b.min), Avg: info.convert(b.avg()), Max: info.convert(b.max),
+			})
+		}
+		snap.Metrics[metric] = sm
+	}
+	return snap
+}
+
+// writeSnapshot saves the snapshot in dir as dashboard-[host-]TIME with
+// .txt (plain), .ans (ANSI colored), .json and .svg extensions. TIME goes
+// down to the millisecond so snapshots taken in the same second don't
+// overwrite each other.
+func writeSnapshot(snap snapshot, dir string, at time.Time) tea.Cmd {
+	return func() tea.Msg {
+		name := "dashboard-"
+		if snap.Host != "" {
+			name += strings.NewReplacer("/", "_", ":", "_").Replace(snap.Host) + "-"
+		}
+		base := filepath.Join(dir, name+at.Format("20060102-150405")+fmt.Sprintf("-%03d", at.Nanosecond()/int(time.Millisecond)))
+
+		data, err := json.MarshalIndent(snap, "", "  ")
+		if err != nil {
+			return snapshotMsg{base: base, err: err}
+		}
+		files := []struct {
+			ext  string
+			data []byte
+		}{
+			{".txt", []byte(ansi.Strip(snap.screen) + "\n")},
+			{".ans", []byte(snap.screen + "\n")},
+			{".json", append(data, '\n')},
+			{".svg", []byte(snap.svg())},
+		}
+		for _, f := range files {
+			if err := os.WriteFile(base+f.ext, f.data, 0o644); err != nil {
+				return snapshotMsg{base: base, err: fmt.Errorf("snapshot: %v", err)}
+			}
+		}
+		return snapshotMsg{base: base}
+	}
+}
+
+// svg draws each metric as a card with its current value and a line chart
+// of its history, two cards to a row.
+func (snap snapshot) svg() string {
+	const (
+		cols    = 2
+		cardW   = 440
+		cardH   = 130
+		gap     = 16
+		titleH  = 44
+		chartX  = 14
+		chartY  = 44
+		chartW  = cardW - 2*chartX
+		chartH  = cardH - chartY - 14
+		fontCSS = "font-family:Menlo,Consolas,monospace"
+	)
+	rows := (len(snap.order) + cols - 1) / cols
+	width := cols*cardW + (cols+1)*gap
+	height := titleH + rows*(cardH+gap) + gap
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" style="%s">`+"\n",
+		width, height, width, height, fontCSS)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="#1A1A1A"/>`+"\n", width, height)
+
+	heading := "System Monitor Dashboard"
+	if snap.Host != "" {
+		heading += " — " + snap.Host
+	}
+	heading += " · " + snap.Time.Format("2006-01-02 15:04:05") + " · window " + snap.Window
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="#7D56F4"/>`+"\n", width, titleH-gap/2)
+	fmt.Fprintf(&b, `<text x="%d" y="%d" fill="#FAFAFA" font-size="16" font-weight="bold" text-anchor="middle">%s</text>`+"\n",
+		width/2, (titleH-gap/2)/2+6, html.EscapeString(heading))
+
+	for i, metric := range snap.order {
+		sm := snap.Metrics[metric]
+		x := gap + (i%cols)*(cardW+gap)
+		y := titleH + (i/cols)*(cardH+gap)
+
+		color := "#04B575"
+		switch sm.Alert {
+		case "pending":
+			color = "#FFB86C"
+		case "firing":
+			color = "#FF5F87"
+		}
+
+		fmt.Fprintf(&b, `<g transform="translate(%d,%d)">`+"\n", x, y)
+		fmt.Fprintf(&b, `<rect width="%d" height="%d" rx="8" fill="#262626" stroke="#626262"/>`+"\n", cardW, cardH)
+		fmt.Fprintf(&b, `<text x="%d" y="26" fill="#FAFAFA" font-size="15" font-weight="bold">%s</text>`+"\n",
+			chartX, html.EscapeString(metric))
+		value := strings.TrimSpace(sm.info.format(sm.Value))
+		if sm.Error != "" {
+			value = "⚠ " + sm.Error
+			color = "#FFB86C"
+		}
+		fmt.Fprintf(&b, `<text x="%d" y="26" fill="%s" font-size="15" text-anchor="end">%s</text>`+"\n",
+			cardW-chartX, color, html.EscapeString(value))
+
+		fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%d" height="%d" fill="#1A1A1A"/>`+"\n", chartX, chartY, chartW, chartH)
+		if pts := sm.svgPoints(snap.Time.Add(-snap.window), snap.window, chartW, chartH); pts != "" {
+			fmt.Fprintf(&b, `<polyline transform="translate(%d,%d)" points="%s" fill="none" stroke="%s" stroke-width="1.5"/>`+"\n",
+				chartX, chartY, pts, color)
+		}
+		b.WriteString("</g>\n")
+	}
+
+	b.WriteString("</svg>\n")
+	return b.String()
+}
+
+// svgPoints plots the history of the window starting at from within a
+// width x height box.
+func (sm snapshotMetric) svgPoints(from time.Time, window time.Duration, width, height int) string {
+	if len(sm.History) == 0 {
+		return ""
+	}
+	avgs := make([]float64, len(sm.History))
+	for i, p := range sm.History {
+		avgs[i] = p.Avg
+	}
+	lo, hi := sm.info.scaleRange(avgs)
+
+	var pts []string
+	for _, p := range sm.History {
+		fx := math.Min(math.Max(p.Start.Sub(from).Seconds()/window.Seconds(), 0), 1)
+		fy := math.Min(math.Max((p.Avg-lo)/(hi-lo), 0), 1)
+		pts = append(pts, fmt.Sprintf("%.1f,%.1f", fx*float64(width), (1-fy)*float64(height)))
+	}
+	return strings.Join(pts, " ")
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSnapshotFiles(t *testing.T) {
+	m := initialModel(options{})
+	m.width, m.height = 100, 40
+	start := time.Unix(1700000000, 0)
+	for i := 0; i < 10; i++ {
+		m, _ = m.applySample(sample{
+			Time:    start.Add(time.Duration(i) * time.Second),
+			Metrics: map[string]float64{"CPU": float64(10 * i), "Memory": 40, "Disk": 70, "Network": 12},
+		})
+	}
+
+	dir := t.TempDir()
+	msg := writeSnapshot(m.snapshot(), dir, start)().(snapshotMsg)
+	if msg.err != nil {
+		t.Fatal(msg.err)
+	}
+
+	read := func(ext string) string {
+		t.Helper()
+		data, err := os.ReadFile(msg.base + ext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	if txt := read(".txt"); strings.Contains(txt, "\x1b[") || !strings.Contains(txt, "System Monitor Dashboard") {
+		t.Errorf("plain text snapshot should be the dashboard without escapes:\n%s", txt)
+	}
+	if read(".ans") == "" {
+		t.Error("ANSI snapshot is empty")
+	}
+
+	var snap snapshot
+	if err := json.Unmarshal([]byte(read(".json")), &snap); err != nil {
+		t.Fatal(err)
+	}
+	cpu := snap.Metrics["CPU"]
+	if cpu.Value != 90 || cpu.Unit != "%" {
+		t.Errorf("CPU = %+v; want value 90, unit %%", cpu)
+	}
+	if len(cpu.History) != 10 || cpu.History[9].Avg != 90 {
+		t.Errorf("CPU history = %+v; want 10 points ending at 90", cpu.History)
+	}
+
+	svg := read(".svg")
+	if !strings.HasPrefix(svg, "<svg") || strings.Count(svg, "<polyline") != 4 {
+		t.Errorf("SVG should have one chart per metric:\n%s", svg)
+	}
+
+	again := writeSnapshot(m.snapshot(), dir, start.Add(250*time.Millisecond))().(snapshotMsg)
+	if again.err != nil {
+		t.Fatal(again.err)
+	}
+	if again.base == msg.base {
+		t.Errorf("snapshots in the same second share the name %s", msg.base)
+	}
+}
+
+func TestSnapshotUsesDisplayUnit(t *testing.T) {
+	m := initialModel(options{})
+	m.infos = map[string]metricInfo{"Network": {unit: "B/s", scale: scaleAuto, factor: 1024}}
+	m, _ = m.applySample(sample{Time: time.Unix(1700000000, 0), Metrics: map[string]float64{"Network": 2}})
+
+	net := m.snapshot().Metrics["Network"]
+	if net.Value != 2048 || net.Unit != "B/s" {
+		t.Errorf("Network = %+v; want value 2048, unit B/s", net)
+	}
+	if len(net.History) != 1 || net.History[0].Max != 2048 {
+		t.Errorf("Network history = %+v; want one point at 2048", net.History)
+	}
+}
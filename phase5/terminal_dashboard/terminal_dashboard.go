@@ -1,66 +1,124 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"math"
+	"net/http"
 	"os"
+	"slices"
 	"strings"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
-	"github.com/shirou/gopsutil/v4/cpu"
-	"github.com/shirou/gopsutil/v4/disk"
-	"github.com/shirou/gopsutil/v4/mem"
-	"github.com/shirou/gopsutil/v4/net"
+	"github.com/charmbracelet/x/ansi"
 )
 
-const barLength int = 30
+// metricOrder is the default display order. Metrics only get a row once
+// they have been collected, so unavailable ones (no swap, no PSI) stay
+// hidden.
+var metricOrder = []string{"CPU", "Load", "Memory", "Swap", "PSI cpu", "PSI mem", "PSI io", "Network", "Disk"}
 
-type lastValues struct {
-	netBytesSent uint64
-	netBytesRecv uint64
+// extraPanels are the panels that aren't a single metric.
+var extraPanels = []string{sensorsPanel, processesPanel}
+
+func defaultPanels() []string {
+	return append(slices.Clip(metricOrder), extraPanels...)
+}
+
+type options struct {
+	interval  time.Duration
+	collector collectorConfig
+	alerts    alertRules
+	hooks     alertHooks
+	recorder  *recorder
+	replay    []sample
+	exporter  *exporter
+	snapshots string
+	config    *configSource
+	file      *dashboardConfig
 }
 
 type model struct {
-	metrics map[string]float64
-	history map[string][]float64
-	last    lastValues
-	width   int
-	height  int
-	time    string
-	status  string
-}
-
-func initialModel() model {
-	return model{
-		metrics: map[string]float64{
-			"CPU":     0,
-			"Memory":  0,
-			"Disk":    0,
-			"Network": 0,
-		},
-		history: map[string][]float64{
-			"CPU":     make([]float64, barLength),
-			"Memory":  make([]float64, barLength),
-			"Disk":    make([]float64, barLength),
-			"Network": make([]float64, barLength),
-		},
-		last: lastValues{
-			netBytesSent: math.MaxUint64,
-			netBytesRecv: math.MaxUint64,
-		},
-		time:   ":",
-		status: "",
-	}
-}
-
-type tickMsg time.Time
+	metrics   map[string]float64
+	history   map[string]*series
+	zoom      int
+	focus     string
+	breakdown bool
+	now       time.Time
+	collector *collector
+	sampler   sampler
+	recorder  *recorder
+	replay    *player
+	exporter  *exporter
+	host      string
+	snapshots string
+	width     int
+	height    int
+	time      string
+	alerts    []*alert
+	hooks     alertHooks
+	health    map[string]*metricHealth
+	round     *round
+	roundID   int
+	events    *eventLog
+
+	// Set by the config file; see applyConfig.
+	config *configSource
+	order  []string
+	infos  map[string]metricInfo
+	colors map[string]lipgloss.Color
+
+	// last is the latest sample, for the detail views.
+	last        sample
+	procSort    int
+	procReverse bool
+}
+
+func initialModel(opts options) model {
+	alerts := make([]*alert, len(opts.alerts))
+	for i, r := range opts.alerts {
+		alerts[i] = &alert{rule: r}
+	}
+
+	m := model{
+		metrics:   map[string]float64{},
+		history:   map[string]*series{},
+		order:     defaultPanels(),
+		config:    opts.config,
+		time:      ":",
+		alerts:    alerts,
+		hooks:     opts.hooks,
+		health:    map[string]*metricHealth{},
+		events:    &eventLog{max: 6},
+		sampler:   newSampler(opts.interval),
+		recorder:  opts.recorder,
+		exporter:  opts.exporter,
+		snapshots: opts.snapshots,
+	}
+	if opts.replay != nil {
+		m.replay = newPlayer(opts.replay)
+	} else {
+		m.collector = newCollector(opts.collector)
+	}
+	if opts.file != nil {
+		m, _ = m.applyConfig(opts.file)
+	}
+
+	return m
+}
 
 func (m model) Init() tea.Cmd {
-	return tea.Tick(time.Second, func(t time.Time) tea.Msg {
-		return tickMsg(t)
-	})
+	var watch tea.Cmd
+	if m.config != nil {
+		watch = m.config.check()
+	}
+	if m.replay != nil {
+		return tea.Batch(m.replay.next(), watch)
+	}
+	return tea.Batch(scheduleTick(m.sampler.delay, m.sampler.gen), watch)
 }
 
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -73,144 +131,554 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		switch msg.String() {
 		case "ctrl+c", "q", "Q":
 			return m, tea.Quit
+		case "z":
+			m.zoom = (m.zoom + 1) % len(zoomWindows)
+			return m, nil
+		case "Z":
+			m.zoom = (m.zoom + len(zoomWindows) - 1) % len(zoomWindows)
+			return m, nil
+		case "s":
+			return m, writeSnapshot(m.snapshot(), m.snapshots, time.Now())
+		}
+		if next, ok := m.focusKey(msg.String()); ok {
+			return next, nil
+		}
+		if m.replay != nil {
+			return m.replayKey(msg.String())
+		}
+		if m.collector != nil {
+			// The sampler methods change m, so they run before m is
+			// returned.
+			switch msg.String() {
+			case "p", " ":
+				cmd := m.sampler.togglePause()
+				return m, cmd
+			case "+", "=":
+				cmd := m.sampler.setInterval(m.sampler.interval / 2)
+				return m, cmd
+			case "-":
+				cmd := m.sampler.setInterval(m.sampler.interval * 2)
+				return m, cmd
+			}
 		}
 
-	case tickMsg:
-		// Update metrics with simulated data
-		cpuPercent := 0.0
-		if v, e := cpu.Percent(0, false); e == nil {
-			cpuPercent = v[0]
-		} else {
-			m.status += " CPU stats read error"
+	case tea.MouseMsg:
+		return m.mouse(msg), nil
+
+	case hookErrMsg:
+		m.events.add(time.Now(), levelWarn, msg.err.Error())
+
+	case configCheckMsg:
+		return m, m.config.check()
+
+	case configMsg:
+		if msg.err != nil {
+			m.events.add(time.Now(), levelWarn, reloadFailed(msg.err))
+			return m, m.config.check()
 		}
-		memPercent := 0.0
-		if v, e := mem.VirtualMemory(); e == nil {
-			memPercent = v.UsedPercent
+		m, cmd := m.applyConfig(msg.cfg)
+		m.events.add(time.Now(), levelInfo, "config reloaded from "+m.config.path)
+		return m, tea.Batch(cmd, m.config.check())
+
+	case snapshotMsg:
+		if msg.err != nil {
+			m.events.add(time.Now(), levelWarn, msg.err.Error())
 		} else {
-			m.status += " Memory stats read error"
+			m.events.add(time.Now(), levelInfo, "snapshot saved to "+msg.base+".{txt,ans,json,svg}")
 		}
-		diskPercent := 0.0
-		if v, e := disk.Usage("/"); e == nil {
-			diskPercent = v.UsedPercent
-		} else {
-			m.status += " Disk stats read error"
+
+	case tickMsg:
+		if msg.gen != m.sampler.gen || m.sampler.paused {
+			return m, nil
 		}
-		netPercentage := 0.0
-		if v, e := net.IOCounters(false); e == nil && len(v) > 0 {
-			if v[0].BytesSent > m.last.netBytesSent {
-				netPercentage += float64(v[0].BytesSent-m.last.netBytesSent) / 100_000.0
-			}
-			if v[0].BytesRecv > m.last.netBytesRecv {
-				netPercentage += float64(v[0].BytesRecv-m.last.netBytesRecv) / 100_000.0
-			}
-			if netPercentage < 0 {
-				netPercentage = 0
-			} else if netPercentage > 100 {
-				netPercentage = 100
-			}
-			m.last.netBytesSent = v[0].BytesSent
-			m.last.netBytesRecv = v[0].BytesRecv
-		} else {
-			m.status += " Network stats read error"
+		if m.round != nil {
+			// The chain was restarted while a round was running. Rather
+			// than queue another round behind it, the running round takes
+			// over the chain and schedules its next tick.
+			m.round.gen = msg.gen
+			return m, nil
+		}
+		m.roundID++
+		r, cmd := m.collector.startRound(m.roundID, msg.time)
+		r.gen = msg.gen
+		m.round = r
+
+		return m, cmd
+
+	case readingMsg:
+		if m.round == nil || msg.round != m.round.id || !m.round.add(msg.reading) {
+			return m, nil
+		}
+		r := m.round
+		m.round = nil
+		// The next tick is scheduled only now, so a slow round backs off
+		// the very next tick and ticks never pile up behind a round.
+		took := time.Since(r.started)
+		m.sampler.delay = nextDelay(m.sampler.interval, took)
+		var next tea.Cmd
+		if r.gen == m.sampler.gen && !m.sampler.paused {
+			next = scheduleTick(max(0, m.sampler.delay-took), r.gen)
+		}
+
+		s := assemble(r.time, r.readings)
+		if m.recorder != nil {
+			m.readResult(s.Time, "Recording", m.recorder.write(s))
+		}
+		if m.exporter != nil {
+			m.exporter.update(s)
 		}
 
-		m.metrics["CPU"] = cpuPercent
-		m.metrics["Memory"] = memPercent
-		m.metrics["Disk"] = diskPercent
-		m.metrics["Network"] = netPercentage
+		m, cmd := m.applySample(s)
+		return m, tea.Batch(next, cmd)
 
-		// Update history
-		for key, value := range m.metrics {
-			m.history[key] = append(m.history[key][1:], value)
+	case replayTickMsg:
+		p := m.replay
+		if msg.gen != p.gen || p.pos >= len(p.samples) {
+			return m, nil
 		}
+		s := p.samples[p.pos]
+		p.pos++
 
-		t := time.Time(msg)
-		m.time = t.Format("3:04:05 PM")
+		m, cmd := m.applySample(s)
 
-		return m, tea.Tick(time.Second, func(t time.Time) tea.Msg {
-			return tickMsg(t)
-		})
+		return m, tea.Batch(p.next(), cmd)
 	}
 
 	return m, nil
 }
 
-func (m model) View() string {
-	if m.width == 0 {
-		return "Loading dashboard..."
+// applySample updates metrics, history and alerts from one sample, whether
+// it was just collected or read back from a recording.
+func (m model) applySample(s sample) (model, tea.Cmd) {
+	// Track the metrics seen so far plus any whose source failed, so a
+	// metric that has never been read still shows why.
+	tracked := map[string]bool{}
+	for metric := range m.metrics {
+		tracked[metric] = true
+	}
+	for metric := range m.health {
+		tracked[metric] = true
+	}
+	for name := range s.Errors {
+		if _, ok := metricInfos[name]; ok {
+			tracked[name] = true
+		}
+	}
+	for _, metric := range sortedKeys(tracked) {
+		m.readResult(s.Time, metric, s.Errors[metric])
+	}
+	for _, name := range panelSources {
+		if s.Errors[name] != nil || m.health[name] != nil {
+			m.readResult(s.Time, name, s.Errors[name])
+		}
+	}
+	for metric, value := range s.Metrics {
+		m.metrics[metric] = value
 	}
 
-	title := lipgloss.NewStyle().
-		Bold(true).
-		Foreground(lipgloss.Color("#FAFAFA")).
-		Background(lipgloss.Color("#7D56F4")).
-		Padding(0, 1).
-		Width(m.width).
-		Align(lipgloss.Center).
-		Render("🖥️  System Monitor Dashboard")
+	// Update history; a metric whose read failed keeps its last value on
+	// screen but leaves a gap in its history. One that has only ever
+	// failed gets an empty history, so its panel has a chart to draw.
+	for metric := range tracked {
+		m.seriesFor(metric)
+	}
+	for key, value := range s.Metrics {
+		m.seriesFor(key).add(s.Time, value)
+	}
+
+	m.now = s.Time
+	m.time = s.Time.Format("3:04:05 PM")
+	// A panel source that failed keeps its last reading on screen, marked
+	// stale, the way a failed metric keeps its last value.
+	if s.Errors["Pressure"] != nil && s.Pressure == nil {
+		s.Pressure = m.last.Pressure
+	}
+	if s.Errors["Cgroup"] != nil && s.Cgroup == nil {
+		s.Cgroup = m.last.Cgroup
+	}
+	if s.Errors[processesPanel] != nil && s.Processes == nil {
+		s.Processes = m.last.Processes
+	}
+	if s.Errors[sensorsPanel] != nil && s.Sensors == nil {
+		s.Sensors = m.last.Sensors
+	}
+	m.last = s
+
+	return m, tea.Batch(m.evaluateAlerts(s.Time)...)
+}
+
+func (m model) replayKey(key string) (tea.Model, tea.Cmd) {
+	p := m.replay
+
+	var cmd tea.Cmd
+	switch key {
+	case " ", "p":
+		return m, p.togglePlay()
+	case "+", "=":
+		p.faster()
+		return m, nil
+	case "-":
+		p.slower()
+		return m, nil
+	case "right":
+		cmd = p.seek(10 * time.Second)
+	case "left":
+		cmd = p.seek(-10 * time.Second)
+	case "shift+right":
+		cmd = p.seek(time.Minute)
+	case "shift+left":
+		cmd = p.seek(-time.Minute)
+	case "home":
+		cmd = p.seek(p.samples[0].Time.Sub(p.samples[len(p.samples)-1].Time))
+	default:
+		return m, nil
+	}
+
+	// Rebuild history from the samples leading up to the new position so
+	// the sparklines match what was on screen at that moment.
+	for key := range m.history {
+		m.history[key] = newSeries()
+	}
+	for _, a := range m.alerts {
+		a.state, a.since = alertOK, time.Time{}
+	}
+	from := 0
+	if p.pos > 0 {
+		cutoff := p.samples[p.pos-1].Time.Add(-zoomWindows[len(zoomWindows)-1])
+		for from < p.pos && p.samples[from].Time.Before(cutoff) {
+			from++
+		}
+	}
+	for _, s := range p.samples[from:p.pos] {
+		m, _ = m.applySample(s)
+	}
 
-	// Create metric displays
-	var metricRows []string
+	return m, cmd
+}
 
-	for _, metric := range []string{"CPU", "Memory", "Network", "Disk"} {
-		value := m.metrics[metric]
-		history := m.history[metric]
+// metricHealth tracks a metric whose reads are failing or timing out.
+type metricHealth struct {
+	err   error
+	since time.Time
+}
 
-		// Create progress bar
-		filled := int(math.Round(float64(barLength) * value / 100))
-		bar := lipgloss.NewStyle().Foreground(lipgloss.Color("#04B575")).Render(
-			fmt.Sprintf("%s%s", strings.Repeat("█", filled), strings.Repeat("░", barLength-filled)),
-		)
+// readResult tracks per-metric read health. It logs an error once when a
+// metric starts failing and again when it recovers, so a persistent failure
+// doesn't flood the log.
+func (m model) readResult(t time.Time, metric string, err error) {
+	h := m.health[metric]
+	switch {
+	case err != nil && h == nil:
+		m.health[metric] = &metricHealth{err: err, since: t}
+		m.events.add(t, levelWarn, fmt.Sprintf("%s stats read error: %v", metric, err))
+	case err != nil:
+		h.err = err
+	case h != nil:
+		delete(m.health, metric)
+		m.events.add(t, levelInfo, metric+" stats recovered")
+	}
+}
 
-		// Create mini sparkline
-		sparkline := lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#7a7f55ff")).
-			Render(m.createSparkline(history, barLength))
+// seriesFor returns metric's history, starting an empty one if needed.
+func (m model) seriesFor(metric string) *series {
+	h, ok := m.history[metric]
+	if !ok {
+		h = newSeries()
+		m.history[metric] = h
+	}
+	return h
+}
+
+// panelSources are the sources that feed something other than a metric of
+// the same name. Their health is tracked under the source's name.
+var panelSources = []string{"Pressure", "Cgroup", processesPanel, sensorsPanel}
+
+// healthOf is the health of the source that reads metric: its own, or for
+// the PSI metrics, the Pressure source's.
+func (m model) healthOf(metric string) *metricHealth {
+	if h := m.health[metric]; h != nil {
+		return h
+	}
+	for _, res := range pressureResources {
+		if res.metric == metric {
+			return m.health["Pressure"]
+		}
+	}
+	return nil
+}
 
-		row := fmt.Sprintf("%-8s %s %5.1f%%\n  %s", metric, bar, value, sparkline)
-		metricRows = append(metricRows, row)
+// healthIndicator describes a failing metric or panel for its row, e.g.
+// "⏱ timeout · stale 12s".
+func (m model) healthIndicator(metric string) string {
+	h := m.healthOf(metric)
+	if h == nil {
+		return ""
 	}
 
-	content := strings.Join(metricRows, "\n\n")
+	var te timeoutError
+	kind := "⚠ error"
+	switch {
+	case errors.As(h.err, &te):
+		kind = "⏱ timeout"
+	case errors.Is(h.err, errInFlight):
+		kind = "⏳ hung"
+	}
+	return fmt.Sprintf("%s · stale %v", kind, m.now.Sub(h.since).Round(time.Second))
+}
 
-	// Add timestamp
-	timeBar := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("#626262")).
-		Render(m.time)
-	footer := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("#626262")).
-		Render(m.status)
+func (m model) evaluateAlerts(t time.Time) []tea.Cmd {
+	var cmds []tea.Cmd
+	for _, a := range m.alerts {
+		value, ok := m.metrics[a.rule.Metric]
+		if !ok {
+			continue
+		}
+		tr := a.evaluate(value, t)
+		if tr == nil {
+			continue
+		}
+		level := levelInfo
+		if tr.fired {
+			level = levelAlert
+		}
+		m.events.add(t, level, tr.String())
+		if cmd := m.hooks.run(*tr); cmd != nil {
+			cmds = append(cmds, cmd)
+		}
+	}
+	return cmds
+}
+
+// alertState returns the most severe state of the alerts on a metric.
+func (m model) alertState(metric string) alertState {
+	state := alertOK
+	for _, a := range m.alerts {
+		if a.rule.Metric == metric && a.state > state {
+			state = a.state
+		}
+	}
+	return state
+}
+
+func (m model) View() string {
+	if m.width == 0 {
+		return "Loading dashboard..."
+	}
+
+	f := m.frame()
+	window := zoomWindows[m.zoom]
+	panels := make([]string, len(f.panels))
+	for i, panel := range f.panels {
+		switch panel {
+		case processesPanel:
+			panels[i] = m.processPanel(f.layout)
+		case sensorsPanel:
+			panels[i] = m.sensorPanel(f.layout)
+		default:
+			panels[i] = m.panel(panel, window, f.layout)
+		}
+	}
+
+	// Pad to a common width so rows stay left-aligned with each other when
+	// the block is centered.
+	content := lipgloss.NewStyle().
+		Width(f.width).
+		Render(f.layout.grid(panels))
+	if f.slices != "" {
+		content = lipgloss.JoinVertical(lipgloss.Left, content, "", f.slices)
+	}
+
+	// The focused panel expanded above the small multiples, sized to
+	// whatever height the panels leave.
+	detail := ""
+	if f.detail > 0 {
+		detail = m.detailView(f)
+	}
 
 	return lipgloss.JoinVertical(
 		lipgloss.Center,
-		title,
+		f.title,
+		"",
+		detail,
 		"",
 		content,
 		"",
 		"",
-		timeBar,
+		f.timeBar,
 		"",
-		footer,
+		m.logPanel(),
 	)
 }
 
-func (m model) createSparkline(data []float64, width int) string {
-	if len(data) == 0 {
-		return strings.Repeat("_", width)
+// visibleMetrics lists the metric panels in display order, leaving out
+// metrics that haven't been collected or failed.
+func (m model) visibleMetrics() []string {
+	var visible []string
+	for _, metric := range m.order {
+		_, collected := m.metrics[metric]
+		if collected || m.health[metric] != nil {
+			visible = append(visible, metric)
+		}
+	}
+	return visible
+}
+
+// info is how a metric is displayed, in the unit the config asks for.
+func (m model) info(metric string) metricInfo {
+	if info, ok := m.infos[metric]; ok {
+		return info
 	}
+	return infoFor(metric)
+}
 
-	// Find min and max for scaling
-	min, max := 33.0, 67.0
-	for _, v := range data {
-		if v < min {
-			min = v
+// panel renders one metric: a bar line, stats and a sparkline, or just the
+// bar line when the layout is compact.
+func (m model) panel(metric string, window time.Duration, l layout) string {
+	value := m.metrics[metric]
+	info := m.info(metric)
+	buckets := m.history[metric].window(m.now, window, l.colWidth)
+
+	history := make([]float64, len(buckets))
+	lo, hi, sum, n := math.Inf(1), math.Inf(-1), 0.0, 0
+	for i, b := range buckets {
+		history[i] = b.avg()
+		if b.n > 0 {
+			lo, hi = math.Min(lo, b.min), math.Max(hi, b.max)
+			sum += b.sum
+			n += b.n
 		}
-		if v > max {
-			max = v
+	}
+	stats := ""
+	if n > 0 {
+		stats = fmt.Sprintf("min %s  avg %s  max %s",
+			strings.TrimSpace(info.format(lo)),
+			strings.TrimSpace(info.format(sum/float64(n))),
+			strings.TrimSpace(info.format(hi)))
+	}
+	scaleLo, scaleHi := info.scaleRange(append(history, value))
+
+	barColor := lipgloss.Color("#04B575")
+	if c, ok := m.colors[metric]; ok {
+		barColor = c
+	}
+	switch m.alertState(metric) {
+	case alertPending:
+		barColor = lipgloss.Color("#FFB86C")
+	case alertFiring:
+		barColor = lipgloss.Color("#FF5F87")
+	}
+
+	indicator := m.healthIndicator(metric)
+	barWidth := l.barWidth()
+	if l.compact && indicator != "" {
+		barWidth = max(1, barWidth-2)
+	}
+
+	// Create progress bar
+	fraction := math.Min(math.Max((value-scaleLo)/(scaleHi-scaleLo), 0), 1)
+	filled := int(math.Round(float64(barWidth) * fraction))
+	bar := lipgloss.NewStyle().Foreground(barColor).Render(
+		fmt.Sprintf("%s%s", strings.Repeat("█", filled), strings.Repeat("░", barWidth-filled)),
+	)
+
+	line := fmt.Sprintf("%s %s %s", m.panelLabel(metric, barColor), bar, info.format(value))
+
+	warn := lipgloss.NewStyle().Foreground(lipgloss.Color("#FFB86C"))
+	if l.compact {
+		if indicator != "" {
+			line += " " + warn.Render("⚠")
 		}
+		return line
+	}
+
+	statsText := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#626262")).
+		Render(ansi.Truncate(stats, l.colWidth, "…"))
+	if indicator != "" {
+		statsText = warn.Render(ansi.Truncate(indicator, l.colWidth, "…"))
 	}
 
-	if max == min {
+	// Create mini sparkline
+	sparkline := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#7a7f55ff")).
+		Render(m.createSparkline(history, l.colWidth, scaleLo, scaleHi))
+
+	return line + "\n" + statsText + "\n" + sparkline
+}
+
+// chartLines plots a metric's history over window as a braille line chart
+// with height plot rows.
+func (m model) chartLines(metric string, window time.Duration, width, height int) string {
+	buckets := m.history[metric].window(m.now, window, 2*width)
+	data := make([]float64, len(buckets))
+	for i, b := range buckets {
+		data[i] = b.avg()
+	}
+	return lineChart(m.info(metric), data, width, height, window)
+}
+
+// slicePanel lists the busiest top-level cgroups when running on a host.
+func (m model) slicePanel(width int) string {
+	if m.last.Cgroup == nil || len(m.last.Cgroup.Slices) == 0 {
+		return ""
+	}
+	const maxSlices = 5
+
+	heading := lipgloss.NewStyle().Bold(true).Render("Cgroups")
+	if indicator := m.healthIndicator("Cgroup"); indicator != "" {
+		heading += "  " + lipgloss.NewStyle().Foreground(lipgloss.Color("#FFB86C")).Render(indicator)
+	}
+	lines := []string{heading}
+	for i, sl := range m.last.Cgroup.Slices {
+		if i == maxSlices {
+			break
+		}
+		name := sl.Name
+		if len(name) > 24 {
+			name = name[:23] + "…"
+		}
+		lines = append(lines, fmt.Sprintf("%-24s  cpu %s  mem %10s", name,
+			formatMetric("CPU", sl.CPUPercent), formatBytes(sl.MemoryUsage)))
+	}
+
+	return lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#626262")).
+		Width(width).
+		Render(strings.Join(lines, "\n"))
+}
+
+func (m model) logPanel() string {
+	if len(m.events.entries) == 0 {
+		return ""
+	}
+
+	colors := map[logLevel]lipgloss.Color{
+		levelInfo:  lipgloss.Color("#626262"),
+		levelWarn:  lipgloss.Color("#FFB86C"),
+		levelAlert: lipgloss.Color("#FF5F87"),
+	}
+
+	lines := make([]string, 0, len(m.events.entries))
+	for _, e := range m.events.entries {
+		lines = append(lines, lipgloss.NewStyle().
+			Foreground(colors[e.level]).
+			Render(e.at.Format("15:04:05")+"  "+e.text))
+	}
+
+	return lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("#626262")).
+		Padding(0, 1).
+		Render(strings.Join(lines, "\n"))
+}
+
+// createSparkline draws data scaled to the lo-hi range, so percentages keep
+// an absolute 0-100 axis while rates use the range of the data in view.
+func (m model) createSparkline(data []float64, width int, lo, hi float64) string {
+	if len(data) == 0 {
+		return strings.Repeat("_", width)
+	}
+	if hi <= lo {
 		return strings.Repeat("▁", width)
 	}
 
@@ -220,11 +688,12 @@ func (m model) createSparkline(data []float64, width int) string {
 
 	for i := 0; i < width && i < len(data); i++ {
 		value := data[i]
-		normalized := (value - min) / (max - min)
-		charIndex := int(normalized * float64(len(chars)-1))
-		if charIndex >= len(chars) {
-			charIndex = len(chars) - 1
+		if math.IsNaN(value) {
+			result.WriteString(" ")
+			continue
 		}
+		normalized := math.Min(math.Max((value-lo)/(hi-lo), 0), 1)
+		charIndex := int(math.Round(normalized * float64(len(chars)-1)))
 		result.WriteString(chars[charIndex])
 	}
 
@@ -238,13 +707,115 @@ func main() {
 	// }
 	// os.Exit(0)
 
+	var opts options
+	flag.Var(&opts.alerts, "alert", "alert rule METRIC>VALUE[:DURATION], e.g. CPU>90:30s (repeatable)")
+	hysteresis := flag.Float64("alert-hysteresis", defaultHysteresis, "how far a metric must recover past the threshold before an alert clears")
+	flag.StringVar(&opts.hooks.exec, "alert-exec", "", "shell command to run when an alert fires or clears")
+	flag.StringVar(&opts.hooks.file, "alert-file", "", "file to append alert transitions to")
+	flag.DurationVar(&opts.interval, "interval", defaultInterval, "how often to collect samples")
+	flag.StringVar(&opts.collector.procRoot, "proc-root", "/proc", "procfs mount to read pressure stall information from")
+	flag.StringVar(&opts.collector.cgroupRoot, "cgroup-root", "/sys/fs/cgroup", "cgroup filesystem mount to read container limits from")
+	flag.StringVar(&opts.collector.sysRoot, "sys-root", "/sys", "sysfs mount to read temperature, fan and battery sensors from")
+	flag.StringVar(&opts.snapshots, "snapshot-dir", ".", "directory to save snapshots to when s is pressed")
+	recordPath := flag.String("record", "", "append every sample to this file")
+	replayPath := flag.String("replay", "", "replay a recording instead of collecting live metrics")
+	metricsAddr := flag.String("metrics-addr", "", "serve Prometheus metrics on this address, e.g. 127.0.0.1:9100")
+	headless := flag.Bool("headless", false, "run as a collection agent without the TUI (requires -metrics-addr)")
+	connect := flag.String("connect", "", "comma-separated agent addresses to view instead of the local host")
+	configPath := flag.String("config", "", "YAML file of panels, thresholds, colors, units and collector options, reloaded when it changes")
+	flag.Parse()
+
+	for i := range opts.alerts {
+		opts.alerts[i].Hysteresis = *hysteresis
+	}
+	if *configPath != "" {
+		file, err := loadConfig(*configPath)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(2)
+		}
+		// Flags given on the command line win over the file.
+		src := &configSource{
+			path:       *configPath,
+			collector:  opts.collector,
+			interval:   opts.interval,
+			alerts:     opts.alerts,
+			hysteresis: *hysteresis,
+			pinned:     map[string]bool{},
+		}
+		flag.Visit(func(f *flag.Flag) { src.pinned[f.Name] = true })
+		src.stat()
+		opts.collector = src.collectorConfig(file)
+		opts.interval = src.sampleInterval(file)
+		opts.alerts = src.rules(file)
+		opts.config, opts.file = src, file
+	}
+
+	if *headless && *metricsAddr == "" {
+		fmt.Println("Error: -headless requires -metrics-addr")
+		os.Exit(2)
+	}
+
+	if *replayPath != "" {
+		samples, err := loadRecording(*replayPath)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		opts.replay = samples
+		// Replaying must not re-run side effects from the original session.
+		opts.hooks = alertHooks{}
+	} else if *recordPath != "" {
+		r, err := openRecorder(*recordPath)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer r.Close()
+		opts.recorder = r
+	}
+
+	if *metricsAddr != "" && opts.replay == nil {
+		opts.exporter = &exporter{}
+		srv := newMetricsServer(*metricsAddr, opts.exporter)
+		if *headless {
+			if err := runHeadless(srv, opts.exporter, opts.recorder, opts.config, opts.collector, clampInterval(opts.interval)); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				if opts.recorder != nil {
+					opts.recorder.Close()
+				}
+				os.Exit(1)
+			}
+			return
+		}
+		go func() {
+			if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				fmt.Fprintf(os.Stderr, "metrics server: %v\n", err)
+			}
+		}()
+		defer srv.Close()
+	}
+
+	if len(opts.alerts) == 0 {
+		opts.alerts = defaultAlertRules(*hysteresis)
+	}
+
+	var root tea.Model = initialModel(opts)
+	if *connect != "" {
+		root = newViewer(strings.Split(*connect, ","), opts)
+	}
+
 	p := tea.NewProgram(
-		initialModel(),
+		root,
 		tea.WithAltScreen(),
+		tea.WithMouseCellMotion(),
 	)
 
 	if _, err := p.Run(); err != nil {
 		fmt.Printf("Error: %v", err)
+		if opts.recorder != nil {
+			opts.recorder.Close()
+		}
 		os.Exit(1)
 	}
 }